@@ -0,0 +1,119 @@
+package foosearch
+
+import (
+	"strings"
+)
+
+// メールアドレスの区分を指定して検索する場合の検索ワードのプレフィックス
+const (
+	// LocalTermPrefix はメールアドレスのローカル部を検索する際のプレフィックス。"local:suzuki" のように指定する
+	LocalTermPrefix = "local:"
+	// DomainTermPrefix はメールアドレスのドメインを検索する際のプレフィックス。"domain:sample.com" のように指定する
+	DomainTermPrefix = "domain:"
+)
+
+// EmailTokenizer はメールアドレスをローカル部とドメインに分けてトークナイズする
+//
+// ローカル部とドメインはそれぞれ全体と、`-`、`.`、`_` で区切った部分をトークンとし、
+// "local:suzuki" や "domain:sample.com" のように区分を付けたトークンと、区分のないトークンを生成する。
+// "mail.sample.com" が "domain:sample.com" にも一致するよう、ドメインは上位のドメインもトークンとする。
+type EmailTokenizer struct{}
+
+// Tokenize はメールアドレスの区分付きのトークンと区分のないトークンを生成する
+func (EmailTokenizer) Tokenize(s string) []string {
+	local, domain := emailTerms(s)
+
+	tokens := make([]string, 0, (len(local)+len(domain))*2)
+	tokens = appendTerms(tokens, LocalTermPrefix, local)
+	tokens = appendTerms(tokens, DomainTermPrefix, domain)
+	tokens = append(tokens, local...)
+	tokens = append(tokens, domain...)
+	return uniqueTokens(tokens)
+}
+
+// QueryTokens は区分付きの検索ワードはそのまま、それ以外は区切り文字で分割したトークンを返す
+func (EmailTokenizer) QueryTokens(s string) []string {
+	if isEmailTerm(s) {
+		return []string{s}
+	}
+	return splitEmail(s)
+}
+
+// emailTerms はメールアドレスのローカル部とドメインのトークンを返す
+func emailTerms(email string) (local, domain []string) {
+	if email == "" {
+		return nil, nil
+	}
+
+	localPart, domainPart := email, ""
+	if i := strings.LastIndex(email, "@"); i >= 0 {
+		localPart, domainPart = email[:i], email[i+1:]
+	}
+
+	if localPart != "" {
+		local = uniqueTokens(append([]string{localPart}, splitEmail(localPart)...))
+	}
+	if domainPart != "" {
+		labels := strings.Split(domainPart, ".")
+		for i := range labels {
+			if suffix := strings.Join(labels[i:], "."); suffix != "" {
+				domain = append(domain, suffix)
+			}
+		}
+		domain = uniqueTokens(append(domain, splitEmail(domainPart)...))
+	}
+	return local, domain
+}
+
+// splitEmail はメールアドレスの区切り文字で文字列を分割する
+func splitEmail(s string) []string {
+	return strings.FieldsFunc(s, func(r rune) bool {
+		return r == '-' || r == '.' || r == '_' || r == '@'
+	})
+}
+
+func appendTerms(tokens []string, prefix string, terms []string) []string {
+	for _, t := range terms {
+		tokens = append(tokens, prefix+t)
+	}
+	return tokens
+}
+
+// isEmailTerm は区分付きの検索ワードかどうかを判定する
+func isEmailTerm(s string) bool {
+	return (strings.HasPrefix(s, LocalTermPrefix) && len(s) > len(LocalTermPrefix)) ||
+		(strings.HasPrefix(s, DomainTermPrefix) && len(s) > len(DomainTermPrefix))
+}
+
+// parseEmailTerms は検索ワードから "local:" と "domain:" の区分付きの語を取り出し、残りの検索ワードと分けて返す
+func parseEmailTerms(text string) (rest string, terms []string) {
+	var words []string
+	for _, w := range strings.Fields(text) {
+		if isEmailTerm(w) {
+			terms = append(terms, w)
+		} else {
+			words = append(words, w)
+		}
+	}
+	if len(terms) == 0 {
+		return text, nil
+	}
+	return strings.Join(words, " "), terms
+}
+
+// hasEmailTerms はメールアドレスが区分付きの検索ワードにすべて一致するかを判定する
+func hasEmailTerms(email string, terms []string) bool {
+	if len(terms) == 0 {
+		return true
+	}
+	tokens := make(map[string]bool)
+	for _, t := range (EmailTokenizer{}).Tokenize(email) {
+		tokens[t] = true
+	}
+	for _, t := range terms {
+		if !tokens[t] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,46 @@
+package foosearch
+
+import (
+	"golang.org/x/net/context"
+)
+
+// EqualSearcher は等価フィルタで検索を行う
+type EqualSearcher struct {
+	Repository
+}
+
+// NewEqualSearcher は指定したリポジトリを検索対象とする EqualSearcher を返す
+func NewEqualSearcher(repo Repository) *EqualSearcher {
+	return &EqualSearcher{Repository: repo}
+}
+
+// Search は指定された項目の完全一致でAND検索を行う
+func (s *EqualSearcher) Search(ctx context.Context, q Query) (*Response, error) {
+	// 完全一致のフィルタのみで検索するため、あいまい検索はできない
+	if q.Fuzzy != 0 {
+		return nil, &InvalidQueryError{Reason: "fuzzy is not supported by equal search"}
+	}
+
+	eq := NewEntityQuery()
+	// クエリパラメータに値が指定されている場合はフィルタ条件を追加する。
+	// FilterをつなげることでAND条件での検索が可能。
+	if q.FamilyName != "" {
+		eq = eq.Filter("FamilyName=", q.FamilyName)
+	}
+	if q.GivenName != "" {
+		eq = eq.Filter("GivenName=", q.GivenName)
+	}
+	if q.Email != "" {
+		eq = eq.Filter("Email=", q.Email)
+	}
+
+	// 等価フィルタと異なるプロパティで並び替える場合は複合インデックスが必要となる
+	resp, err := getPage(ctx, s.Repository, withOrders(eq, q.Sort), q.Limit, q.Cursor)
+	if err != nil {
+		return nil, err
+	}
+	if resp.Facets, err = scanFacets(ctx, s.Repository, []*EntityQuery{eq}, nil, q.Facets); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
@@ -0,0 +1,140 @@
+package foosearch
+
+import (
+	"sort"
+	"strings"
+
+	"golang.org/x/net/context"
+	"google.golang.org/appengine/datastore"
+)
+
+// MaxFacetValues は項目ごとに返す値の件数の上限
+const MaxFacetValues = 10
+
+// facetValues は集計できる項目と、Fooから集計する値を取得する関数の対応
+var facetValues = map[string]func(*Foo) string{
+	"domain":     func(f *Foo) string { return emailDomain(f.Email) },
+	"familyName": func(f *Foo) string { return f.FamilyName },
+}
+
+// FacetCount は集計した値ごとの件数
+type FacetCount struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
+
+// ParseFacets は `domain,familyName` 形式の文字列を集計する項目の一覧に変換する
+func ParseFacets(s string) ([]string, error) {
+	if s == "" {
+		return nil, nil
+	}
+
+	var (
+		facets []string
+		seen   = make(map[string]bool)
+	)
+	for _, name := range strings.Split(s, ",") {
+		name = strings.TrimSpace(name)
+		if _, ok := facetValues[name]; !ok {
+			return nil, &InvalidQueryError{Reason: "unknown facet: " + name}
+		}
+		if seen[name] {
+			return nil, &InvalidQueryError{Reason: "duplicated facet: " + name}
+		}
+		seen[name] = true
+		facets = append(facets, name)
+	}
+	return facets, nil
+}
+
+// emailDomain はメールアドレスのドメインを小文字にして返す
+func emailDomain(email string) string {
+	i := strings.LastIndex(email, "@")
+	if i < 0 {
+		return ""
+	}
+	return strings.ToLower(email[i+1:])
+}
+
+// countFacets は項目ごとに、Fooの値ごとの件数を集計する。項目が指定されていない場合はnilを返す
+func countFacets(foos []*Foo, names []string) map[string][]FacetCount {
+	if len(names) == 0 {
+		return nil
+	}
+	facets := make(map[string][]FacetCount, len(names))
+	for _, name := range names {
+		counts := make(map[string]int)
+		for _, f := range foos {
+			if v := facetValues[name](f); v != "" {
+				counts[v]++
+			}
+		}
+		facets[name] = topFacetCounts(counts)
+	}
+	return facets
+}
+
+// scanFacets はクエリに一致するFooのうち、match を満たすものを集計する
+//
+// 候補はページの取得とは別に、キーのみのクエリで合計 DefaultMaxScan 件まで読み込み、まとめて1回で取得する。
+// 複数のクエリに一致したFooは1件として集計し、DefaultMaxScan 件を超える候補は件数に含まれない。
+// match がnilの場合はすべての候補を集計する。
+func scanFacets(ctx context.Context, repo Repository, queries []*EntityQuery, match func(*Foo) bool, names []string) (map[string][]FacetCount, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+
+	var (
+		ids  []int64
+		seen = make(map[int64]bool)
+	)
+	for _, eq := range queries {
+		if len(ids) == DefaultMaxScan {
+			break
+		}
+		t := repo.Run(ctx, eq.KeysOnly().Limit(DefaultMaxScan-len(ids)))
+		for {
+			f, err := t.Next()
+			if err == datastore.Done {
+				break
+			} else if err != nil {
+				return nil, err
+			}
+			if !seen[f.ID] {
+				seen[f.ID] = true
+				ids = append(ids, f.ID)
+			}
+		}
+	}
+
+	foos, err := repo.GetMulti(ctx, ids)
+	// 集計中に削除されたFooは件数に含めない
+	if _, err := orphanIDs(ids, err); err != nil {
+		return nil, err
+	}
+	matched := make([]*Foo, 0, len(foos))
+	for _, f := range foos {
+		if f != nil && (match == nil || match(f)) {
+			matched = append(matched, f)
+		}
+	}
+	return countFacets(matched, names), nil
+}
+
+// topFacetCounts は件数の多い順に MaxFacetValues 件までの値を返す。件数が同じ場合は値の順とする
+func topFacetCounts(counts map[string]int) []FacetCount {
+	ret := make([]FacetCount, 0, len(counts))
+	for v, n := range counts {
+		ret = append(ret, FacetCount{Value: v, Count: n})
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		if ret[i].Count != ret[j].Count {
+			return ret[i].Count > ret[j].Count
+		}
+		return ret[i].Value < ret[j].Value
+	})
+	if len(ret) > MaxFacetValues {
+		ret = ret[:MaxFacetValues]
+	}
+	return ret
+}
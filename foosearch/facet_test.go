@@ -0,0 +1,95 @@
+package foosearch
+
+import (
+	"reflect"
+	"testing"
+
+	"golang.org/x/net/context"
+)
+
+func TestParseFacets(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    []string
+		invalid bool
+	}{
+		{"", nil, false},
+		{"domain", []string{"domain"}, false},
+		{"familyName, domain", []string{"familyName", "domain"}, false},
+		{"email", nil, true},
+		{"domain,domain", nil, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseFacets(tt.in)
+		if _, ok := err.(*InvalidQueryError); ok != tt.invalid {
+			t.Errorf("ParseFacets(%q) error = %v", tt.in, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseFacets(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestSearchersFacets(t *testing.T) {
+	tests := []struct {
+		name     string
+		strategy func(Repository) Strategy
+		query    Query
+		want     map[string][]FacetCount
+	}{
+		{
+			name:     "equal",
+			strategy: func(r Repository) Strategy { return NewEqualSearcher(r) },
+			query:    Query{FamilyName: "鈴木"},
+			want:     map[string][]FacetCount{"domain": {{"example.com", 1}, {"sample.com", 1}}},
+		},
+		{
+			name:     "or",
+			strategy: func(r Repository) Strategy { return NewOrSearcher(r) },
+			query:    Query{FamilyName: "鈴木", GivenName: "太郎"},
+			want: map[string][]FacetCount{
+				"domain":     {{"sample.com", 5}, {"example.com", 1}},
+				"familyName": {{"鈴木", 2}, {"メロン", 1}, {"ロンメロ", 1}, {"山田", 1}, {"田中", 1}},
+			},
+		},
+		{
+			name:     "ngram",
+			strategy: func(r Repository) Strategy { return NewNGramSearcher(r) },
+			query:    Query{Text: "山田"},
+			want:     map[string][]FacetCount{"domain": {{"example.com", 1}, {"sample.com", 1}}},
+		},
+		{
+			name:     "ngram fuzzy",
+			strategy: func(r Repository) Strategy { return NewNGramSearcher(r) },
+			query:    Query{Text: "yamda", Fuzzy: 1},
+			want:     map[string][]FacetCount{"familyName": {{"山田", 2}}},
+		},
+		{
+			name:     "query language",
+			strategy: func(r Repository) Strategy { return NewQuerySearcher(r) },
+			query:    Query{Text: "familyName:鈴* OR givenName:花子"},
+			want:     map[string][]FacetCount{"familyName": {{"鈴木", 2}, {"山田", 1}}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := tt.strategy(NewMemoryRepository())
+			putFoos(t, s)
+
+			q := tt.query
+			for name := range tt.want {
+				q.Facets = append(q.Facets, name)
+			}
+			// 件数はページに含まれないFooも集計する
+			q.Limit = 1
+			resp, err := s.Search(context.Background(), q)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(resp.Facets, tt.want) {
+				t.Errorf("Search(%+v) facets = %v, want %v", q, resp.Facets, tt.want)
+			}
+		})
+	}
+}
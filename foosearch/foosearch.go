@@ -0,0 +1,120 @@
+// Package foosearch は各サンプルで共通して利用する、fooエンティティの検索処理をまとめたパッケージ。
+//
+// 検索方式ごとに Searcher の実装を用意しており、サンプルのアプリケーションは
+// 利用したい方式の Searcher を NewRouter に渡すだけで検索APIを構築できる。
+package foosearch
+
+import (
+	"golang.org/x/net/context"
+)
+
+// Foo は検索対象となるエンティティ
+type Foo struct {
+	ID         int64 `datastore:"-"`
+	FamilyName string
+	GivenName  string
+	Email      string
+	// FamilyNameKana と GivenNameKana は姓と名の読み(ふりがな)。任意の項目で、設定した場合は読みとローマ字でも検索できる
+	FamilyNameKana string
+	GivenNameKana  string
+	// Search は検索インデックスとして使用する複数値プロパティ。検索方式によって設定される
+	Search []string `json:"-"`
+}
+
+// Query は検索条件
+type Query struct {
+	Text       string // `q` パラメータ。全文検索やSearch APIのクエリ文字列として扱う
+	FamilyName string
+	GivenName  string
+	Email      string
+
+	// Sort は検索結果の並び順。指定しない場合は検索方式ごとのデフォルトの順となる
+	Sort []SortOrder
+
+	// Limit は取得する件数。0の場合は DefaultLimit 件取得する
+	Limit int
+	// Cursor は前回の検索結果の NextCursor。指定した場合は続きから取得する
+	Cursor string
+
+	// Explain は検索方式の実行計画をレスポンスに含めるかどうか。QuerySearcher でのみ有効
+	Explain bool
+
+	// Facets は値ごとの件数を集計する項目。"domain" と "familyName" を指定できる
+	Facets []string
+
+	// Fuzzy はあいまい検索で許容する編集距離。0の場合は完全に含まれるもののみ検索する。NGramSearcher でのみ有効
+	Fuzzy int
+}
+
+// Result は検索結果の1件分
+type Result struct {
+	*Foo
+	// MatchedFields は検索条件に一致した項目。OR検索の場合のみ設定される
+	MatchedFields []string `json:"matchedFields,omitempty"`
+	// Score は検索ワードとの関連度。順位付けを行う場合のみ設定される
+	Score float64 `json:"score,omitempty"`
+	// Highlights は項目ごとの、検索ワードに一致した部分を <b> タグで囲んだHTML。一致した項目のみ設定される
+	Highlights map[string]string `json:"highlights,omitempty"`
+	// Fuzzy は検索ワードがそのまま含まれず、あいまい検索で一致した場合に true となる
+	Fuzzy bool `json:"fuzzy,omitempty"`
+}
+
+// Response は検索結果の一覧と、検索処理に関する付加情報
+type Response struct {
+	Items []Result `json:"items"`
+	// NextCursor は続きの検索結果を取得するためのカーソル
+	NextCursor string `json:"nextCursor"`
+	HasMore    bool   `json:"hasMore"`
+	// Dropped はインデックスにはヒットしたものの、実データが存在しなかったため除外した件数
+	Dropped int `json:"dropped,omitempty"`
+	// Rejected はインデックスでは候補となったものの、実際の値で検証した結果一致しなかったため除外した件数
+	Rejected int `json:"rejected,omitempty"`
+	// Plan は Query.Explain を指定した場合の実行計画
+	Plan *QueryPlan `json:"plan,omitempty"`
+	// Facets は Query.Facets を指定した場合の、項目ごとの値と件数
+	Facets map[string][]FacetCount `json:"facets,omitempty"`
+	// Truncated は候補が読み込み件数の上限を超えたため、読み込んだ候補のみで結果を求めた場合にtrueとなる
+	// 上限を超えた候補は HasMore や NextCursor によって取得することはできない
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// InvalidQueryError は検索条件が不正な場合のエラー
+type InvalidQueryError struct {
+	Reason string
+}
+
+func (e *InvalidQueryError) Error() string {
+	return "foosearch: invalid query: " + e.Reason
+}
+
+// Searcher は検索方式ごとの検索処理を表す
+type Searcher interface {
+	Search(ctx context.Context, q Query) (*Response, error)
+}
+
+// Store はFooの取得・保存・削除を行う
+//
+// 保存と削除の際には、検索方式ごとに必要なインデックスも合わせて更新する。
+type Store interface {
+	Get(ctx context.Context, id int64) (*Foo, error)
+	// PutMulti はIDが0のFooは新規に作成し、それ以外は指定のIDのFooを置き換える
+	PutMulti(ctx context.Context, foos []*Foo) ([]int64, error)
+	DeleteMulti(ctx context.Context, ids []int64) error
+}
+
+// Strategy は検索とデータの更新の両方を備えた検索方式
+//
+// 各 Searcher の実装は Repository を埋め込んでおり、インデックスの更新が不要な方式は
+// Repository の操作がそのまま Store の実装となる。
+type Strategy interface {
+	Searcher
+	Store
+}
+
+func newResponse(foos []*Foo) *Response {
+	results := make([]Result, 0, len(foos))
+	for _, f := range foos {
+		results = append(results, Result{Foo: f})
+	}
+	return &Response{Items: results}
+}
@@ -0,0 +1,266 @@
+package foosearch
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+
+	"golang.org/x/net/context"
+	"google.golang.org/appengine/datastore"
+)
+
+const utf8LastChar = "\xef\xbf\xbd"
+
+// ForwardMatchSearcher は比較フィルタで前方一致検索を行う
+//
+// 比較フィルタは複数のプロパティに指定できないため、複数の項目が指定された場合は
+// 最も絞り込めるプロパティにのみ比較フィルタを指定し、残りの項目はメモリ上で絞り込む。
+type ForwardMatchSearcher struct {
+	Repository
+	// MaxScan はメモリ上で絞り込む際に、1回の検索で読み込むエンティティの件数の上限
+	MaxScan int
+}
+
+// NewForwardMatchSearcher は指定したリポジトリを検索対象とする ForwardMatchSearcher を返す
+func NewForwardMatchSearcher(repo Repository) *ForwardMatchSearcher {
+	return &ForwardMatchSearcher{Repository: repo, MaxScan: DefaultMaxScan}
+}
+
+// prefixCondition は1つのプロパティに対する前方一致の条件
+type prefixCondition struct {
+	property string
+	prefix   string
+}
+
+func (c prefixCondition) query(eq *EntityQuery) *EntityQuery {
+	return eq.Filter(c.property+" >=", c.prefix).Filter(c.property+" <=", c.prefix+utf8LastChar)
+}
+
+func (c prefixCondition) match(f *Foo) bool {
+	values, _ := propertyValues(f, c.property)
+	return strings.HasPrefix(firstValue(values), c.prefix)
+}
+
+// prefixConditions は検索条件の各項目を前方一致の条件に変換する
+func prefixConditions(q Query) []prefixCondition {
+	var conds []prefixCondition
+	if q.FamilyName != "" {
+		conds = append(conds, prefixCondition{property: "FamilyName", prefix: q.FamilyName})
+	}
+	if q.GivenName != "" {
+		conds = append(conds, prefixCondition{property: "GivenName", prefix: q.GivenName})
+	}
+	if q.Email != "" {
+		conds = append(conds, prefixCondition{property: "Email", prefix: q.Email})
+	}
+	return conds
+}
+
+// highlightPrefixes は前方一致した項目の先頭を強調して検索結果に設定する
+func highlightPrefixes(resp *Response, conds []prefixCondition) {
+	for i := range resp.Items {
+		r := &resp.Items[i]
+		for _, c := range conds {
+			values, _ := propertyValues(r.Foo, c.property)
+			if v := firstValue(values); strings.HasPrefix(v, c.prefix) {
+				r.setHighlight(c.property, highlightSpans(v, []span{{start: 0, end: len(c.prefix)}}))
+			}
+		}
+	}
+}
+
+// Search は指定された項目の前方一致で検索を行う
+func (s *ForwardMatchSearcher) Search(ctx context.Context, q Query) (*Response, error) {
+	// 範囲フィルタで前方一致を判定するため、あいまい検索はできない
+	if q.Fuzzy != 0 {
+		return nil, &InvalidQueryError{Reason: "fuzzy is not supported by forward match search"}
+	}
+
+	conds := prefixConditions(q)
+	resp, err := s.search(ctx, q, conds)
+	if err != nil {
+		return nil, err
+	}
+	highlightPrefixes(resp, conds)
+	return resp, nil
+}
+
+func (s *ForwardMatchSearcher) search(ctx context.Context, q Query, conds []prefixCondition) (*Response, error) {
+	eq := withOrders(NewEntityQuery(), q.Sort)
+	if len(conds) == 0 {
+		resp, err := getPage(ctx, s.Repository, eq, q.Limit, q.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		resp.Facets, err = scanFacets(ctx, s.Repository, []*EntityQuery{NewEntityQuery()}, nil, q.Facets)
+		return resp, err
+	}
+
+	if len(conds) == 1 {
+		if _, err := s.rangeCondition(ctx, conds, q.Sort); err != nil {
+			return nil, err
+		}
+		resp, err := getPage(ctx, s.Repository, conds[0].query(eq), q.Limit, q.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		resp.Facets, err = scanFacets(ctx, s.Repository, []*EntityQuery{conds[0].query(NewEntityQuery())}, nil, q.Facets)
+		return resp, err
+	}
+
+	// 続きのページでは件数を数え直さず、カーソルに記録した最初のページと同じ条件を使用する
+	// 件数はページごとに変わることがあり、異なる条件のクエリではカーソルが使用できないため
+	var (
+		rangeIdx int
+		cursor   rangeCursor
+		err      error
+	)
+	if q.Cursor == "" {
+		rangeIdx, err = s.rangeCondition(ctx, conds, q.Sort)
+	} else if cursor, err = decodeRangeCursor(q.Cursor); err == nil {
+		rangeIdx, err = pinnedCondition(conds, q.Sort, cursor.Property)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// 比較フィルタを指定しなかった条件はメモリ上で絞り込む
+	rest := append(append([]prefixCondition(nil), conds[:rangeIdx]...), conds[rangeIdx+1:]...)
+	match := func(f *Foo) bool {
+		return matchAll(f, rest)
+	}
+	resp, err := scanPage(ctx, s.Repository, conds[rangeIdx].query(eq), q.Limit, cursor.Cursor, s.maxScan(), match)
+	if err != nil {
+		return nil, err
+	}
+	if resp.Facets, err = scanFacets(ctx, s.Repository, []*EntityQuery{conds[rangeIdx].query(NewEntityQuery())}, match, q.Facets); err != nil {
+		return nil, err
+	}
+	if resp.HasMore {
+		cursor = rangeCursor{Property: conds[rangeIdx].property, Cursor: resp.NextCursor}
+		if resp.NextCursor, err = cursor.encode(); err != nil {
+			return nil, err
+		}
+	}
+	return resp, nil
+}
+
+// rangeCursor は比較フィルタを指定したプロパティと、そのクエリの読み込み位置
+type rangeCursor struct {
+	Property string `json:"property"`
+	Cursor   string `json:"cursor"`
+}
+
+func (c rangeCursor) encode() (string, error) {
+	b, err := json.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func decodeRangeCursor(cursor string) (rangeCursor, error) {
+	var c rangeCursor
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil || json.Unmarshal(b, &c) != nil || c.Property == "" {
+		return rangeCursor{}, &InvalidQueryError{Reason: "invalid cursor"}
+	}
+	return c, nil
+}
+
+// pinnedCondition はカーソルに記録されたプロパティの条件を返す
+// 検索条件や並び順が最初のページと異なり、そのプロパティの条件が使用できない場合はエラーとする
+func pinnedCondition(conds []prefixCondition, orders []SortOrder, property string) (int, error) {
+	if len(orders) != 0 && orders[0].Property != property {
+		return 0, &InvalidQueryError{Reason: "invalid cursor"}
+	}
+	for i, c := range conds {
+		if c.property == property {
+			return i, nil
+		}
+	}
+	return 0, &InvalidQueryError{Reason: "invalid cursor"}
+}
+
+// rangeCondition は比較フィルタを指定する条件を選ぶ
+//
+// Datastoreでは比較フィルタを使用したプロパティを最初の並び順に指定する必要があるため、
+// 並び順が指定されている場合はそのプロパティを使用する。
+// それ以外の場合は、各条件に一致する件数を MaxScan 件まで数え、最も件数の少ない条件を使用する。
+func (s *ForwardMatchSearcher) rangeCondition(ctx context.Context, conds []prefixCondition, orders []SortOrder) (int, error) {
+	if len(orders) != 0 {
+		for i, c := range conds {
+			if c.property == orders[0].Property {
+				return i, nil
+			}
+		}
+		return 0, &InvalidQueryError{Reason: "the first sort field must be one of the prefix-matched fields"}
+	}
+	if len(conds) == 1 {
+		return 0, nil
+	}
+
+	var (
+		wg     = new(sync.WaitGroup)
+		mux    = new(sync.Mutex)
+		counts = make([]int, len(conds))
+		errs   []error
+	)
+	for i, c := range conds {
+		wg.Add(1)
+		go func(i int, c prefixCondition) {
+			defer wg.Done()
+			n, err := countUpTo(ctx, s.Repository, c.query(NewEntityQuery()), s.maxScan())
+
+			mux.Lock()
+			defer mux.Unlock()
+			if err == nil {
+				counts[i] = n
+			} else {
+				errs = append(errs, err)
+			}
+		}(i, c)
+	}
+	wg.Wait()
+
+	if len(errs) != 0 {
+		return 0, fmt.Errorf("%v", errs)
+	}
+	min := 0
+	for i, n := range counts {
+		if n < counts[min] {
+			min = i
+		}
+	}
+	return min, nil
+}
+
+func (s *ForwardMatchSearcher) maxScan() int {
+	return maxScan(s.MaxScan)
+}
+
+func matchAll(f *Foo, conds []prefixCondition) bool {
+	for _, c := range conds {
+		if !c.match(f) {
+			return false
+		}
+	}
+	return true
+}
+
+// countUpTo はクエリに一致する件数を最大n件まで数える
+func countUpTo(ctx context.Context, repo Repository, eq *EntityQuery, n int) (int, error) {
+	t := repo.Run(ctx, eq.KeysOnly().Limit(n))
+	count := 0
+	for {
+		_, err := t.Next()
+		if err == datastore.Done {
+			return count, nil
+		} else if err != nil {
+			return 0, err
+		}
+		count++
+	}
+}
@@ -0,0 +1,46 @@
+package foosearch
+
+import (
+	"reflect"
+	"testing"
+
+	"golang.org/x/net/context"
+)
+
+func TestForwardMatchSearcherCursor(t *testing.T) {
+	s := NewForwardMatchSearcher(NewMemoryRepository())
+	if _, err := s.PutMulti(context.Background(), []*Foo{{FamilyName: "田島", GivenName: "五郎", Email: "k-tajima@sample.com"}}); err != nil {
+		t.Fatal(err)
+	}
+	putFoos(t, s)
+
+	// 件数の少ないメールアドレスの比較フィルタで1ページ目を取得する
+	q := Query{FamilyName: "田", Email: "ta", Limit: 1}
+	resp, err := s.Search(context.Background(), q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := fullNames(resp.Items)
+
+	// メールアドレスの件数が増えても、続きのページは1ページ目と同じ項目の比較フィルタで取得する
+	if _, err := s.PutMulti(context.Background(), []*Foo{
+		{FamilyName: "佐藤", GivenName: "一郎", Email: "ta1@sample.com"},
+		{FamilyName: "佐藤", GivenName: "二郎", Email: "ta2@sample.com"},
+		{FamilyName: "佐藤", GivenName: "三郎", Email: "ta3@sample.com"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; resp.HasMore; i++ {
+		if i > len(testFoos) {
+			t.Fatal("paging does not end")
+		}
+		q.Cursor = resp.NextCursor
+		if resp, err = s.Search(context.Background(), q); err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, fullNames(resp.Items)...)
+	}
+	if want := []string{"田中太郎", "田所三郎"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("paged Search() = %v, want %v", got, want)
+	}
+}
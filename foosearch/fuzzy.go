@@ -0,0 +1,299 @@
+package foosearch
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"unicode/utf8"
+
+	"golang.org/x/net/context"
+	"google.golang.org/appengine/datastore"
+)
+
+// MaxFuzzyDistance はあいまい検索で許容できる編集距離の上限
+const MaxFuzzyDistance = 2
+
+// fuzzyWord はあいまい検索を行う検索ワードと、その検索ワードで候補を生成するNGramの項目
+type fuzzyWord struct {
+	word  string
+	field ngramField
+	// n はNGramの文字数
+	n int
+	// values は検索ワードと比較する項目の値を返す
+	values func(family, given, email []string) []string
+}
+
+// fuzzyWords はあいまい検索を行う検索ワードを返す
+// NGram以外のトークナイザを使用している場合はNGramのトークンで候補を生成できないため、エラーを返す
+func (s *NGramSearcher) fuzzyWords(q Query) ([]fuzzyWord, error) {
+	all, family, given, email := s.fields()
+
+	var words []fuzzyWord
+	for _, w := range []struct {
+		word   string
+		field  ngramField
+		values func(family, given, email []string) []string
+	}{
+		{q.Text, all, func(family, given, email []string) []string {
+			return append(append(append([]string(nil), family...), given...), email...)
+		}},
+		{q.FamilyName, family, func(family, _, _ []string) []string { return family }},
+		{q.GivenName, given, func(_, given, _ []string) []string { return given }},
+		{q.Email, email, func(_, _, email []string) []string { return email }},
+	} {
+		if w.word == "" {
+			continue
+		}
+		t, ok := w.field.tokenizer.(NGramTokenizer)
+		if !ok {
+			return nil, &InvalidQueryError{Reason: "fuzzy search is only supported with n-gram tokens"}
+		}
+		words = append(words, fuzzyWord{word: w.word, field: w.field, n: ngramSize(t.N), values: w.values})
+	}
+	return words, nil
+}
+
+// fuzzyPage は検索ワードとの編集距離が maxDistance 以内の文字列を含むFooを検索し、カーソルの位置から最大limit件取得する
+//
+// 候補は検索ワードのNGramのトークンごとにクエリを実行し、一定数以上のトークンを含むFooとする。
+// 編集距離が d の場合、検索ワードのNGramのうち最大 d×N 個が一致しなくなるため、
+// 残りのトークンの数を下限として候補を絞り込み、実際の文字列との編集距離を計算して検証する。
+// 検索結果は並び順の指定があればその順、なければ編集距離の小さい順(Scoring を指定した場合は、同じ距離の中でスコアの高い順)とし、
+// 検索ワードがそのまま含まれないFooは Result.Fuzzy を設定して返す。
+// トークンごとのFooや候補の件数が MaxScan 件を超えた場合は、読み込んだ候補のみで結果を求め、Response.Truncated を設定する。
+func (s *NGramSearcher) fuzzyPage(ctx context.Context, q Query, words []fuzzyWord, terms []string) (*Response, error) {
+	offset, err := decodeOffsetCursor(q.Cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	ids, truncated, err := s.fuzzyCandidates(ctx, words, q.Fuzzy)
+	if err != nil {
+		return nil, err
+	}
+	foos, err := s.Repository.GetMulti(ctx, ids)
+	orphans, err := orphanIDs(ids, err)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		matched   []*Foo
+		distances = make(map[int64]int)
+		rejected  int
+	)
+	for _, f := range foos {
+		if f == nil {
+			continue
+		}
+		nf := s.Normalizer.normalizeFoo(f)
+		d, ok := fuzzyDistance(nf, words, q.Fuzzy)
+		if !ok || !hasEmailTerms(nf.Email, terms) {
+			rejected++
+			continue
+		}
+		matched = append(matched, f)
+		distances[f.ID] = d
+	}
+
+	var results []Result
+	if s.Scoring != nil && len(q.Sort) == 0 {
+		results = s.rank(ctx, matched, q)
+	} else {
+		results = sortResults(matched, q.Sort)
+	}
+	for i := range results {
+		results[i].Fuzzy = distances[results[i].ID] > 0
+	}
+	if len(q.Sort) == 0 {
+		sort.SliceStable(results, func(i, j int) bool {
+			return distances[results[i].ID] < distances[results[j].ID]
+		})
+	}
+
+	if offset > len(results) {
+		offset = len(results)
+	}
+	resp := &Response{Rejected: rejected, Dropped: len(orphans), Truncated: truncated, Facets: countFacets(matched, q.Facets)}
+	if end := offset + pageLimit(q.Limit); end < len(results) {
+		resp.Items = results[offset:end]
+		resp.NextCursor, resp.HasMore = encodeOffsetCursor(end), true
+	} else {
+		resp.Items = results[offset:]
+	}
+	return resp, nil
+}
+
+// fuzzyCandidates は検索ワードごとに候補となるFooのIDを求め、すべての検索ワードの候補となったIDを MaxScan 件まで返す
+// 読み込み件数の上限により候補の一部を読み込めなかった場合は、truncated にtrueを返す
+func (s *NGramSearcher) fuzzyCandidates(ctx context.Context, words []fuzzyWord, maxDistance int) (ids []int64, truncated bool, err error) {
+	var candidates map[int64]bool
+	for _, w := range words {
+		found, t, err := s.fuzzyWordCandidates(ctx, w, maxDistance)
+		if err != nil {
+			return nil, false, err
+		}
+		truncated = truncated || t
+		next := make(map[int64]bool, len(found))
+		for _, id := range found {
+			if candidates == nil || candidates[id] {
+				next[id] = true
+			}
+		}
+		candidates = next
+	}
+
+	ids = make([]int64, 0, len(candidates))
+	for id := range candidates {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	if max := maxScan(s.MaxScan); len(ids) > max {
+		ids, truncated = ids[:max], true
+	}
+	return ids, truncated, nil
+}
+
+// fuzzyWordCandidates は検索ワードのNGramのトークンごとにキーのみのクエリを並列に実行し、
+// 一定数以上のトークンを含むFooのIDを返す
+func (s *NGramSearcher) fuzzyWordCandidates(ctx context.Context, w fuzzyWord, maxDistance int) ([]int64, bool, error) {
+	// NGramの文字数に満たない検索ワードはUnigramで候補を生成する
+	n := w.n
+	if utf8.RuneCountInString(w.word) < n {
+		n = 1
+	}
+	tokens := uniqueTokens(nGram(w.word, n, w.field.prefix))
+	threshold := len(tokens) - maxDistance*n
+	if threshold < 1 && n > 1 {
+		// 編集によってすべてのNGramが一致しなくなる可能性がある場合は、Unigramで候補を生成する
+		n = 1
+		tokens = uniqueTokens(nGram(w.word, n, w.field.prefix))
+		threshold = len(tokens) - maxDistance
+	}
+	// 検索ワードが短く、それでも下限が決まらない場合は、いずれかのトークンを含むFooを候補とする
+	if threshold < 1 {
+		threshold = 1
+	}
+
+	var (
+		wg        = new(sync.WaitGroup)
+		mux       = new(sync.Mutex)
+		counts    = make(map[int64]int)
+		truncated bool
+		errs      []error
+	)
+	for _, t := range tokens {
+		wg.Add(1)
+		go func(t string) {
+			defer wg.Done()
+			ids, more, err := s.tokenIDs(ctx, t)
+
+			mux.Lock()
+			defer mux.Unlock()
+			if err != nil {
+				errs = append(errs, err)
+				return
+			}
+			truncated = truncated || more
+			for _, id := range ids {
+				counts[id]++
+			}
+		}(t)
+	}
+	wg.Wait()
+
+	if len(errs) != 0 {
+		return nil, false, fmt.Errorf("%v", errs)
+	}
+	var ids []int64
+	for id, c := range counts {
+		if c >= threshold {
+			ids = append(ids, id)
+		}
+	}
+	return ids, truncated, nil
+}
+
+// tokenIDs はトークンを含むFooのIDを MaxScan 件まで返す
+// MaxScan 件を超えるFooがトークンを含む場合は、2番目の戻り値にtrueを返す
+func (s *NGramSearcher) tokenIDs(ctx context.Context, token string) ([]int64, bool, error) {
+	max := maxScan(s.MaxScan)
+	t := s.Repository.Run(ctx, NewEntityQuery().Filter("Search=", token).KeysOnly().Limit(max+1))
+	var ids []int64
+	for {
+		f, err := t.Next()
+		if err == datastore.Done {
+			return ids, false, nil
+		} else if err != nil {
+			return nil, false, err
+		}
+		if len(ids) == max {
+			return ids, true, nil
+		}
+		ids = append(ids, f.ID)
+	}
+}
+
+// fuzzyDistance はFooの各項目と検索ワードとの編集距離のうち、検索ワードごとの最小値の最大を返す
+// いずれかの検索ワードが maxDistance 以内で一致しない場合は false を返す
+func fuzzyDistance(f *Foo, words []fuzzyWord, maxDistance int) (int, bool) {
+	family, given, email := searchableValues(f)
+	distance := 0
+	for _, w := range words {
+		min := maxDistance + 1
+		for _, v := range w.values(family, given, email) {
+			if d := substringDistance(v, w.word, maxDistance); d < min {
+				min = d
+			}
+		}
+		if min > maxDistance {
+			return 0, false
+		}
+		if min > distance {
+			distance = min
+		}
+	}
+	return distance, true
+}
+
+// substringDistance は文字列のいずれかの部分文字列と検索ワードとの編集距離(レーベンシュタイン距離)の最小値を返す
+//
+// 部分文字列はどの位置から始まってもよいため、1行目をすべて0として動的計画法で計算する。
+// 途中で max を超えることが確定した場合は、計算を打ち切って max+1 を返す。
+func substringDistance(text, word string, max int) int {
+	var (
+		t    = []rune(text)
+		w    = []rune(word)
+		prev = make([]int, len(t)+1)
+		cur  = make([]int, len(t)+1)
+	)
+	for i := 1; i <= len(w); i++ {
+		cur[0] = i
+		rowMin := cur[0]
+		for j := 1; j <= len(t); j++ {
+			cost := 1
+			if w[i-1] == t[j-1] {
+				cost = 0
+			}
+			cur[j] = minInt(prev[j-1]+cost, minInt(prev[j]+1, cur[j-1]+1))
+			rowMin = minInt(rowMin, cur[j])
+		}
+		if rowMin > max {
+			return max + 1
+		}
+		prev, cur = cur, prev
+	}
+
+	d := prev[0]
+	for _, v := range prev {
+		d = minInt(d, v)
+	}
+	return d
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,79 @@
+package foosearch
+
+import (
+	"reflect"
+	"testing"
+
+	"golang.org/x/net/context"
+)
+
+func TestSubstringDistance(t *testing.T) {
+	tests := []struct {
+		text, word string
+		max        int
+		want       int
+	}{
+		{"tanaka@sample.com", "tanaka", 2, 0},
+		{"tanaka", "tanka", 2, 1},
+		{"yamada", "yamda", 2, 1},
+		{"鈴木一郎", "鈴本", 2, 1},
+		{"", "ab", 2, 2},
+		{"abc", "xyz", 1, 2},
+	}
+	for _, tt := range tests {
+		if got := substringDistance(tt.text, tt.word, tt.max); got != tt.want {
+			t.Errorf("substringDistance(%q, %q, %d) = %d, want %d", tt.text, tt.word, tt.max, got, tt.want)
+		}
+	}
+}
+
+func TestNGramSearcherFuzzy(t *testing.T) {
+	tests := []struct {
+		query Query
+		want  []string
+		fuzzy []bool
+	}{
+		{Query{Text: "yamda", Fuzzy: 1}, []string{"山田花子", "山田太郎"}, []bool{true, true}},
+		{Query{Text: "yamada", Fuzzy: 1}, []string{"山田花子", "山田太郎"}, []bool{false, false}},
+		// 読みのローマ字表記とも比較する
+		{Query{FamilyName: "tanka", Fuzzy: 1}, []string{"田中太郎"}, []bool{true}},
+		{Query{FamilyName: "tanka", Fuzzy: 0}, nil, nil},
+		// 編集距離の小さい順に返す
+		{Query{Text: "suzuki2", Fuzzy: 1}, []string{"一郎鈴木", "鈴木一郎", "鈴木次郎"}, []bool{false, true, true}},
+	}
+	for _, tt := range tests {
+		s := NewNGramSearcher(NewMemoryRepository())
+		putFoos(t, s)
+
+		resp, err := s.Search(context.Background(), tt.query)
+		if err != nil {
+			t.Errorf("Search(%+v) error = %v", tt.query, err)
+			continue
+		}
+		if got := fullNames(resp.Items); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Search(%+v) = %v, want %v", tt.query, got, tt.want)
+			continue
+		}
+		var fuzzy []bool
+		for _, r := range resp.Items {
+			fuzzy = append(fuzzy, r.Fuzzy)
+		}
+		if !reflect.DeepEqual(fuzzy, tt.fuzzy) {
+			t.Errorf("Search(%+v) Fuzzy = %v, want %v", tt.query, fuzzy, tt.fuzzy)
+		}
+	}
+}
+
+func TestNGramSearcherFuzzyTruncated(t *testing.T) {
+	s := NewNGramSearcher(NewMemoryRepository())
+	s.MaxScan = 1
+	putFoos(t, s)
+
+	resp, err := s.Search(context.Background(), Query{Text: "sample", Fuzzy: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !resp.Truncated || len(resp.Items) != 1 {
+		t.Errorf("Truncated = %v, items = %d, want true, 1", resp.Truncated, len(resp.Items))
+	}
+}
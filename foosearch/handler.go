@@ -0,0 +1,322 @@
+package foosearch
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"golang.org/x/net/context"
+	"google.golang.org/appengine"
+	"google.golang.org/appengine/datastore"
+)
+
+// MaxBulkFoos は一度に登録できるFooの件数の上限。Datastoreで一度に保存できるエンティティの件数に合わせている
+const MaxBulkFoos = 500
+
+// DroppedHeader はインデックスにはヒットしたが実データが存在せず、検索結果から除外した件数を返すヘッダ
+const DroppedHeader = "X-Search-Dropped"
+
+// NewRouter は検索とFooの操作を行うエンドポイントを設定したルータを返す
+func NewRouter(s Strategy) *mux.Router {
+	r := mux.NewRouter()
+
+	r.HandleFunc("/foos", SearchHandler(s)).Methods(http.MethodGet)
+	r.HandleFunc("/foos", CreateHandler(s)).Methods(http.MethodPost)
+	r.HandleFunc("/foos/{id:[0-9]+}", GetHandler(s)).Methods(http.MethodGet)
+	r.HandleFunc("/foos/{id:[0-9]+}", UpdateHandler(s)).Methods(http.MethodPut)
+	r.HandleFunc("/foos/{id:[0-9]+}", PatchHandler(s)).Methods(http.MethodPatch)
+	r.HandleFunc("/foos/{id:[0-9]+}", DeleteHandler(s)).Methods(http.MethodDelete)
+
+	return r
+}
+
+// SearchHandler はクエリパラメータを検索条件として検索を行うハンドラを返す
+func SearchHandler(s Searcher) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := appengine.NewContext(r)
+
+		// 検索ワードの取得
+		q := Query{
+			Text:       r.FormValue("q"),
+			FamilyName: r.FormValue("familyName"),
+			GivenName:  r.FormValue("givenName"),
+			Email:      r.FormValue("email"),
+			Cursor:     r.FormValue("cursor"),
+		}
+		sort, err := ParseSort(r.FormValue("sort"))
+		if err != nil {
+			httpSearchError(w, err)
+			return
+		}
+		q.Sort = sort
+		facets, err := ParseFacets(r.FormValue("facets"))
+		if err != nil {
+			httpSearchError(w, err)
+			return
+		}
+		q.Facets = facets
+		if l := r.FormValue("limit"); l != "" {
+			limit, err := strconv.Atoi(l)
+			if err != nil || limit <= 0 || limit > MaxLimit {
+				http.Error(w, fmt.Sprintf("limit must be between 1 and %d", MaxLimit), http.StatusBadRequest)
+				return
+			}
+			q.Limit = limit
+		}
+		if f := r.FormValue("fuzzy"); f != "" {
+			fuzzy, err := strconv.Atoi(f)
+			if err != nil || fuzzy < 0 || fuzzy > MaxFuzzyDistance {
+				http.Error(w, fmt.Sprintf("fuzzy must be between 0 and %d", MaxFuzzyDistance), http.StatusBadRequest)
+				return
+			}
+			q.Fuzzy = fuzzy
+		}
+		if e := r.FormValue("explain"); e != "" {
+			explain, err := strconv.ParseBool(e)
+			if err != nil {
+				http.Error(w, "explain must be a boolean", http.StatusBadRequest)
+				return
+			}
+			q.Explain = explain
+		}
+
+		resp, err := s.Search(ctx, q)
+		if err != nil {
+			httpSearchError(w, err)
+			return
+		}
+
+		// 検索結果から除外した件数は、レスポンスの dropped とあわせてレスポンスヘッダでも返す
+		w.Header().Set(DroppedHeader, strconv.Itoa(resp.Dropped))
+		writeJSON(w, http.StatusOK, resp)
+	}
+}
+
+// CreateHandler はリクエストボディのFooを登録するハンドラを返す
+//
+// リクエストボディには単一のオブジェクトか、オブジェクトの配列を指定できる。
+func CreateHandler(s Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := appengine.NewContext(r)
+
+		foos, single, err := decodeFoos(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		// IDは採番されたものを使用するため、リクエストで指定された値は無視する
+		for _, f := range foos {
+			f.ID = 0
+		}
+
+		if _, err := s.PutMulti(ctx, foos); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		if single {
+			w.Header().Set("Location", fmt.Sprintf("/foos/%d", foos[0].ID))
+			writeJSON(w, http.StatusCreated, foos[0])
+			return
+		}
+		writeJSON(w, http.StatusCreated, foos)
+	}
+}
+
+// GetHandler はIDを指定してFooを取得するハンドラを返す
+func GetHandler(s Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := appengine.NewContext(r)
+
+		foo, err := s.Get(ctx, pathID(r))
+		if err != nil {
+			httpStoreError(w, err)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, foo)
+	}
+}
+
+// UpdateHandler はIDを指定してFooを置き換えるハンドラを返す
+func UpdateHandler(s Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := appengine.NewContext(r)
+
+		id := pathID(r)
+		foo := new(Foo)
+		if err := json.NewDecoder(r.Body).Decode(foo); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		// 存在しないFooを作成しないように、更新前に存在を確認する
+		// 確認から保存までの間に削除されないよう、トランザクション内で行う
+		foo.ID = id
+		err := runInTransaction(ctx, func(tc context.Context) error {
+			if _, err := s.Get(tc, id); err != nil {
+				return err
+			}
+			_, err := s.PutMulti(tc, []*Foo{foo})
+			return err
+		})
+		if err != nil {
+			httpStoreError(w, err)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, foo)
+	}
+}
+
+// fooPatch はPATCHリクエストで指定された項目のみを保持する
+type fooPatch struct {
+	FamilyName     *string
+	GivenName      *string
+	Email          *string
+	FamilyNameKana *string
+	GivenNameKana  *string
+}
+
+func (p *fooPatch) apply(f *Foo) {
+	if p.FamilyName != nil {
+		f.FamilyName = *p.FamilyName
+	}
+	if p.GivenName != nil {
+		f.GivenName = *p.GivenName
+	}
+	if p.Email != nil {
+		f.Email = *p.Email
+	}
+	if p.FamilyNameKana != nil {
+		f.FamilyNameKana = *p.FamilyNameKana
+	}
+	if p.GivenNameKana != nil {
+		f.GivenNameKana = *p.GivenNameKana
+	}
+}
+
+// PatchHandler はIDを指定してFooの一部の項目を更新するハンドラを返す
+func PatchHandler(s Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := appengine.NewContext(r)
+
+		patch := new(fooPatch)
+		if err := json.NewDecoder(r.Body).Decode(patch); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		// 取得から保存までの間に他の更新が行われると上書きしてしまうため、トランザクション内で行う
+		var foo *Foo
+		err := runInTransaction(ctx, func(tc context.Context) error {
+			f, err := s.Get(tc, pathID(r))
+			if err != nil {
+				return err
+			}
+			patch.apply(f)
+			if _, err := s.PutMulti(tc, []*Foo{f}); err != nil {
+				return err
+			}
+			foo = f
+			return nil
+		})
+		if err != nil {
+			httpStoreError(w, err)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, foo)
+	}
+}
+
+// DeleteHandler はIDを指定してFooを削除するハンドラを返す
+func DeleteHandler(s Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := appengine.NewContext(r)
+
+		id := pathID(r)
+		err := runInTransaction(ctx, func(tc context.Context) error {
+			if _, err := s.Get(tc, id); err != nil {
+				return err
+			}
+			return s.DeleteMulti(tc, []int64{id})
+		})
+		if err != nil {
+			httpStoreError(w, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// decodeFoos はリクエストボディから単一または配列のFooを読み込む
+func decodeFoos(r *http.Request) (foos []*Foo, single bool, err error) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return nil, false, err
+	}
+	body = bytes.TrimSpace(body)
+	if len(body) == 0 {
+		return nil, false, fmt.Errorf("request body is empty")
+	}
+
+	if body[0] == '[' {
+		if err := json.Unmarshal(body, &foos); err != nil {
+			return nil, false, err
+		}
+		if len(foos) == 0 {
+			return nil, false, fmt.Errorf("request body has no foo")
+		}
+		if len(foos) > MaxBulkFoos {
+			return nil, false, fmt.Errorf("request body has too many foos (max %d)", MaxBulkFoos)
+		}
+		for _, f := range foos {
+			if f == nil {
+				return nil, false, fmt.Errorf("request body contains null")
+			}
+		}
+		return foos, false, nil
+	}
+
+	foo := new(Foo)
+	if err := json.Unmarshal(body, foo); err != nil {
+		return nil, false, err
+	}
+	return []*Foo{foo}, true, nil
+}
+
+// pathID はURLのパスからFooのIDを取得する
+// ルーティングで数値のみを受け付けているため、変換エラーは発生しない
+func pathID(r *http.Request) int64 {
+	id, _ := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	return id
+}
+
+func httpSearchError(w http.ResponseWriter, err error) {
+	if _, ok := err.(*InvalidQueryError); ok {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	http.Error(w, err.Error(), http.StatusInternalServerError)
+}
+
+func httpStoreError(w http.ResponseWriter, err error) {
+	if err == datastore.ErrNoSuchEntity {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	http.Error(w, err.Error(), http.StatusInternalServerError)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	body, _ := json.MarshalIndent(v, "", "  ")
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(body)
+}
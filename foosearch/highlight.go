@@ -0,0 +1,143 @@
+package foosearch
+
+import (
+	"bytes"
+	"html"
+	"strings"
+	"unicode/utf8"
+)
+
+// 検索結果の強調箇所を囲むタグ
+const (
+	highlightStart = "<b>"
+	highlightEnd   = "</b>"
+)
+
+// highlightFields は強調箇所を探す項目
+var highlightFields = []string{"FamilyName", "GivenName", "Email", "FamilyNameKana", "GivenNameKana"}
+
+// highlightWord は強調する検索ワードと、そのトークン
+type highlightWord struct {
+	word   string
+	tokens []string
+}
+
+// span は文字列中のバイト位置の範囲 [start, end)
+type span struct {
+	start, end int
+}
+
+// highlightSpans は文字列中の範囲をタグで囲む。重なる範囲や隣接する範囲はまとめて囲む
+// HTMLとして扱えるよう、タグ以外の部分はエスケープする
+func highlightSpans(s string, spans []span) string {
+	if len(spans) == 0 {
+		return ""
+	}
+	marked := make([]bool, len(s))
+	for _, sp := range spans {
+		for i := sp.start; i < sp.end; i++ {
+			marked[i] = true
+		}
+	}
+
+	var buf bytes.Buffer
+	for start := 0; start < len(s); {
+		end := start
+		for end < len(s) && marked[end] == marked[start] {
+			end++
+		}
+		if marked[start] {
+			buf.WriteString(highlightStart + html.EscapeString(s[start:end]) + highlightEnd)
+		} else {
+			buf.WriteString(html.EscapeString(s[start:end]))
+		}
+		start = end
+	}
+	return buf.String()
+}
+
+// tokenSpans は文字列中でいずれかのトークンが出現するすべての範囲を返す
+func tokenSpans(s string, tokens []string) []span {
+	var spans []span
+	for _, t := range tokens {
+		if t == "" {
+			continue
+		}
+		for offset := 0; offset < len(s); {
+			i := strings.Index(s[offset:], t)
+			if i < 0 {
+				break
+			}
+			start := offset + i
+			spans = append(spans, span{start: start, end: start + len(t)})
+			// NGramは重なって出現するため、1文字ずつずらして探す
+			_, width := utf8.DecodeRuneInString(s[start:])
+			offset = start + width
+		}
+	}
+	return spans
+}
+
+// normalizedSpans は正規化した文字列の中でトークンが出現する範囲を、元の文字列での範囲に変換して返す
+//
+// 元の文字列での位置を求めるため、1文字ずつ正規化して正規化後の位置と元の位置を対応付ける。
+// 前後の文字によって結果が変わる正規化(長音記号の扱いなど)は反映されないため、その部分は強調されないことがある。
+func normalizedSpans(n *Normalizer, s string, tokens []string) []span {
+	var (
+		buf bytes.Buffer
+		// orig は正規化後のバイト位置ごとの、元の文字列での文字の範囲
+		orig []span
+	)
+	for i := 0; i < len(s); {
+		_, width := utf8.DecodeRuneInString(s[i:])
+		ns := n.Normalize(s[i : i+width])
+		for j := 0; j < len(ns); j++ {
+			orig = append(orig, span{start: i, end: i + width})
+		}
+		buf.WriteString(ns)
+		i += width
+	}
+
+	spans := tokenSpans(buf.String(), tokens)
+	for i, sp := range spans {
+		spans[i] = span{start: orig[sp.start].start, end: orig[sp.end-1].end}
+	}
+	return spans
+}
+
+// setHighlights は項目ごとの検索ワードが出現する範囲を、検索結果の元の値で強調して設定する
+//
+// 項目の値と検索ワードは n で正規化して比較し、検索ワードがそのまま含まれない項目は、そのトークンが出現する範囲を強調する。
+func setHighlights(resp *Response, n *Normalizer, words map[string][]highlightWord) {
+	for i := range resp.Items {
+		resp.Items[i].highlight(n, words)
+	}
+}
+
+// highlight は項目ごとの検索ワードが出現する範囲を、元の値で強調して設定する
+func (r *Result) highlight(n *Normalizer, words map[string][]highlightWord) {
+	for _, field := range highlightFields {
+		values, _ := propertyValues(r.Foo, field)
+		v := firstValue(values)
+		var spans []span
+		for _, w := range words[field] {
+			sp := normalizedSpans(n, v, []string{w.word})
+			if len(sp) == 0 {
+				sp = normalizedSpans(n, v, w.tokens)
+			}
+			spans = append(spans, sp...)
+		}
+		r.setHighlight(field, highlightSpans(v, spans))
+	}
+}
+
+// setHighlight は強調した文字列を検索結果に設定する。強調箇所がない場合は設定しない
+func (r *Result) setHighlight(field, fragment string) {
+	if fragment == "" {
+		return
+	}
+	if r.Highlights == nil {
+		r.Highlights = make(map[string]string)
+	}
+	r.Highlights[field] = fragment
+}
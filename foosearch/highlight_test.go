@@ -0,0 +1,53 @@
+package foosearch
+
+import (
+	"reflect"
+	"testing"
+
+	"golang.org/x/net/context"
+)
+
+func TestSearchersHighlights(t *testing.T) {
+	tests := []struct {
+		name     string
+		strategy func(Repository) Strategy
+		query    Query
+		want     map[string]string
+	}{
+		{
+			name:     "ngram",
+			strategy: func(r Repository) Strategy { return NewNGramSearcher(r) },
+			query:    Query{Text: "ロン"},
+			want:     map[string]string{"FamilyName": "メ<b>ロン</b>"},
+		},
+		{
+			name:     "forward match",
+			strategy: func(r Repository) Strategy { return NewForwardMatchSearcher(r) },
+			query:    Query{FamilyName: "メ"},
+			want:     map[string]string{"FamilyName": "<b>メ</b>ロン"},
+		},
+		{
+			name:     "query language",
+			strategy: func(r Repository) Strategy { return NewQuerySearcher(r) },
+			query:    Query{Text: "familyName:メ* ロン"},
+			want:     map[string]string{"FamilyName": "<b>メロン</b>"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := tt.strategy(NewMemoryRepository())
+			putFoos(t, s)
+
+			resp, err := s.Search(context.Background(), tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(resp.Items) == 0 {
+				t.Fatalf("Search(%+v) returned no items", tt.query)
+			}
+			if got := resp.Items[0].Highlights; !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Search(%+v) highlights = %v, want %v", tt.query, got, tt.want)
+			}
+		})
+	}
+}
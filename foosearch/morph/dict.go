@@ -0,0 +1,402 @@
+package morph
+
+// defaultEntries は埋め込みの辞書の見出し語
+//
+// 人名の検索に使用することを想定し、主な姓と名、助詞、接尾辞を収録している。
+// 同じ品詞の中では、よく使われる語ほどコストを小さくしている。
+var defaultEntries = []Entry{
+	// 姓
+	{Surface: "佐藤", Reading: "サトウ", Class: Surname, Cost: 2000},
+	{Surface: "鈴木", Reading: "スズキ", Class: Surname, Cost: 2005},
+	{Surface: "高橋", Reading: "タカハシ", Class: Surname, Cost: 2010},
+	{Surface: "田中", Reading: "タナカ", Class: Surname, Cost: 2015},
+	{Surface: "伊藤", Reading: "イトウ", Class: Surname, Cost: 2020},
+	{Surface: "渡辺", Reading: "ワタナベ", Class: Surname, Cost: 2025},
+	{Surface: "山本", Reading: "ヤマモト", Class: Surname, Cost: 2030},
+	{Surface: "中村", Reading: "ナカムラ", Class: Surname, Cost: 2035},
+	{Surface: "小林", Reading: "コバヤシ", Class: Surname, Cost: 2040},
+	{Surface: "加藤", Reading: "カトウ", Class: Surname, Cost: 2045},
+	{Surface: "吉田", Reading: "ヨシダ", Class: Surname, Cost: 2050},
+	{Surface: "山田", Reading: "ヤマダ", Class: Surname, Cost: 2055},
+	{Surface: "佐々木", Reading: "ササキ", Class: Surname, Cost: 2060},
+	{Surface: "山口", Reading: "ヤマグチ", Class: Surname, Cost: 2065},
+	{Surface: "松本", Reading: "マツモト", Class: Surname, Cost: 2070},
+	{Surface: "井上", Reading: "イノウエ", Class: Surname, Cost: 2075},
+	{Surface: "木村", Reading: "キムラ", Class: Surname, Cost: 2080},
+	{Surface: "林", Reading: "ハヤシ", Class: Surname, Cost: 2085},
+	{Surface: "斎藤", Reading: "サイトウ", Class: Surname, Cost: 2090},
+	{Surface: "清水", Reading: "シミズ", Class: Surname, Cost: 2095},
+	{Surface: "山崎", Reading: "ヤマザキ", Class: Surname, Cost: 2100},
+	{Surface: "森", Reading: "モリ", Class: Surname, Cost: 2105},
+	{Surface: "池田", Reading: "イケダ", Class: Surname, Cost: 2110},
+	{Surface: "橋本", Reading: "ハシモト", Class: Surname, Cost: 2115},
+	{Surface: "阿部", Reading: "アベ", Class: Surname, Cost: 2120},
+	{Surface: "石川", Reading: "イシカワ", Class: Surname, Cost: 2125},
+	{Surface: "山下", Reading: "ヤマシタ", Class: Surname, Cost: 2130},
+	{Surface: "中島", Reading: "ナカジマ", Class: Surname, Cost: 2135},
+	{Surface: "石井", Reading: "イシイ", Class: Surname, Cost: 2140},
+	{Surface: "小川", Reading: "オガワ", Class: Surname, Cost: 2145},
+	{Surface: "前田", Reading: "マエダ", Class: Surname, Cost: 2150},
+	{Surface: "岡田", Reading: "オカダ", Class: Surname, Cost: 2155},
+	{Surface: "長谷川", Reading: "ハセガワ", Class: Surname, Cost: 2160},
+	{Surface: "藤田", Reading: "フジタ", Class: Surname, Cost: 2165},
+	{Surface: "後藤", Reading: "ゴトウ", Class: Surname, Cost: 2170},
+	{Surface: "近藤", Reading: "コンドウ", Class: Surname, Cost: 2175},
+	{Surface: "村上", Reading: "ムラカミ", Class: Surname, Cost: 2180},
+	{Surface: "遠藤", Reading: "エンドウ", Class: Surname, Cost: 2185},
+	{Surface: "青木", Reading: "アオキ", Class: Surname, Cost: 2190},
+	{Surface: "坂本", Reading: "サカモト", Class: Surname, Cost: 2195},
+	{Surface: "斉藤", Reading: "サイトウ", Class: Surname, Cost: 2200},
+	{Surface: "福田", Reading: "フクダ", Class: Surname, Cost: 2205},
+	{Surface: "太田", Reading: "オオタ", Class: Surname, Cost: 2210},
+	{Surface: "西村", Reading: "ニシムラ", Class: Surname, Cost: 2215},
+	{Surface: "藤井", Reading: "フジイ", Class: Surname, Cost: 2220},
+	{Surface: "金子", Reading: "カネコ", Class: Surname, Cost: 2225},
+	{Surface: "岡本", Reading: "オカモト", Class: Surname, Cost: 2230},
+	{Surface: "藤原", Reading: "フジワラ", Class: Surname, Cost: 2235},
+	{Surface: "中野", Reading: "ナカノ", Class: Surname, Cost: 2240},
+	{Surface: "三浦", Reading: "ミウラ", Class: Surname, Cost: 2245},
+	{Surface: "原田", Reading: "ハラダ", Class: Surname, Cost: 2250},
+	{Surface: "中川", Reading: "ナカガワ", Class: Surname, Cost: 2255},
+	{Surface: "松田", Reading: "マツダ", Class: Surname, Cost: 2260},
+	{Surface: "竹内", Reading: "タケウチ", Class: Surname, Cost: 2265},
+	{Surface: "小野", Reading: "オノ", Class: Surname, Cost: 2270},
+	{Surface: "田村", Reading: "タムラ", Class: Surname, Cost: 2275},
+	{Surface: "中山", Reading: "ナカヤマ", Class: Surname, Cost: 2280},
+	{Surface: "和田", Reading: "ワダ", Class: Surname, Cost: 2285},
+	{Surface: "石田", Reading: "イシダ", Class: Surname, Cost: 2290},
+	{Surface: "森田", Reading: "モリタ", Class: Surname, Cost: 2295},
+	{Surface: "上田", Reading: "ウエダ", Class: Surname, Cost: 2300},
+	{Surface: "原", Reading: "ハラ", Class: Surname, Cost: 2305},
+	{Surface: "内田", Reading: "ウチダ", Class: Surname, Cost: 2310},
+	{Surface: "柴田", Reading: "シバタ", Class: Surname, Cost: 2315},
+	{Surface: "酒井", Reading: "サカイ", Class: Surname, Cost: 2320},
+	{Surface: "宮崎", Reading: "ミヤザキ", Class: Surname, Cost: 2325},
+	{Surface: "横山", Reading: "ヨコヤマ", Class: Surname, Cost: 2330},
+	{Surface: "高木", Reading: "タカギ", Class: Surname, Cost: 2335},
+	{Surface: "安藤", Reading: "アンドウ", Class: Surname, Cost: 2340},
+	{Surface: "宮本", Reading: "ミヤモト", Class: Surname, Cost: 2345},
+	{Surface: "大野", Reading: "オオノ", Class: Surname, Cost: 2350},
+	{Surface: "小島", Reading: "コジマ", Class: Surname, Cost: 2355},
+	{Surface: "谷口", Reading: "タニグチ", Class: Surname, Cost: 2360},
+	{Surface: "今井", Reading: "イマイ", Class: Surname, Cost: 2365},
+	{Surface: "工藤", Reading: "クドウ", Class: Surname, Cost: 2370},
+	{Surface: "高田", Reading: "タカダ", Class: Surname, Cost: 2375},
+	{Surface: "増田", Reading: "マスダ", Class: Surname, Cost: 2380},
+	{Surface: "丸山", Reading: "マルヤマ", Class: Surname, Cost: 2385},
+	{Surface: "杉山", Reading: "スギヤマ", Class: Surname, Cost: 2390},
+	{Surface: "村田", Reading: "ムラタ", Class: Surname, Cost: 2395},
+	{Surface: "大塚", Reading: "オオツカ", Class: Surname, Cost: 2400},
+	{Surface: "新井", Reading: "アライ", Class: Surname, Cost: 2405},
+	{Surface: "小山", Reading: "コヤマ", Class: Surname, Cost: 2410},
+	{Surface: "平野", Reading: "ヒラノ", Class: Surname, Cost: 2415},
+	{Surface: "藤本", Reading: "フジモト", Class: Surname, Cost: 2420},
+	{Surface: "河野", Reading: "コウノ", Class: Surname, Cost: 2425},
+	{Surface: "上野", Reading: "ウエノ", Class: Surname, Cost: 2430},
+	{Surface: "野口", Reading: "ノグチ", Class: Surname, Cost: 2435},
+	{Surface: "武田", Reading: "タケダ", Class: Surname, Cost: 2440},
+	{Surface: "松井", Reading: "マツイ", Class: Surname, Cost: 2445},
+	{Surface: "千葉", Reading: "チバ", Class: Surname, Cost: 2450},
+	{Surface: "岩崎", Reading: "イワサキ", Class: Surname, Cost: 2455},
+	{Surface: "菅原", Reading: "スガワラ", Class: Surname, Cost: 2460},
+	{Surface: "木下", Reading: "キノシタ", Class: Surname, Cost: 2465},
+	{Surface: "久保", Reading: "クボ", Class: Surname, Cost: 2470},
+	{Surface: "佐野", Reading: "サノ", Class: Surname, Cost: 2475},
+	{Surface: "野村", Reading: "ノムラ", Class: Surname, Cost: 2480},
+	{Surface: "松尾", Reading: "マツオ", Class: Surname, Cost: 2485},
+	{Surface: "市川", Reading: "イチカワ", Class: Surname, Cost: 2490},
+	{Surface: "菊地", Reading: "キクチ", Class: Surname, Cost: 2495},
+	{Surface: "杉本", Reading: "スギモト", Class: Surname, Cost: 2500},
+	{Surface: "古川", Reading: "フルカワ", Class: Surname, Cost: 2505},
+	{Surface: "大西", Reading: "オオニシ", Class: Surname, Cost: 2510},
+	{Surface: "島田", Reading: "シマダ", Class: Surname, Cost: 2515},
+	{Surface: "水野", Reading: "ミズノ", Class: Surname, Cost: 2520},
+	{Surface: "桜井", Reading: "サクライ", Class: Surname, Cost: 2525},
+	{Surface: "高野", Reading: "タカノ", Class: Surname, Cost: 2530},
+	{Surface: "渡部", Reading: "ワタナベ", Class: Surname, Cost: 2535},
+	{Surface: "吉川", Reading: "ヨシカワ", Class: Surname, Cost: 2540},
+	{Surface: "山内", Reading: "ヤマウチ", Class: Surname, Cost: 2545},
+	{Surface: "西田", Reading: "ニシダ", Class: Surname, Cost: 2550},
+	{Surface: "飯田", Reading: "イイダ", Class: Surname, Cost: 2555},
+	{Surface: "菊池", Reading: "キクチ", Class: Surname, Cost: 2560},
+	{Surface: "西川", Reading: "ニシカワ", Class: Surname, Cost: 2565},
+	{Surface: "小松", Reading: "コマツ", Class: Surname, Cost: 2570},
+	{Surface: "北村", Reading: "キタムラ", Class: Surname, Cost: 2575},
+	{Surface: "安田", Reading: "ヤスダ", Class: Surname, Cost: 2580},
+	{Surface: "五十嵐", Reading: "イガラシ", Class: Surname, Cost: 2585},
+	{Surface: "川口", Reading: "カワグチ", Class: Surname, Cost: 2590},
+	{Surface: "平田", Reading: "ヒラタ", Class: Surname, Cost: 2595},
+	{Surface: "関", Reading: "セキ", Class: Surname, Cost: 2600},
+	{Surface: "中田", Reading: "ナカタ", Class: Surname, Cost: 2605},
+	{Surface: "久保田", Reading: "クボタ", Class: Surname, Cost: 2610},
+	{Surface: "服部", Reading: "ハットリ", Class: Surname, Cost: 2615},
+	{Surface: "東", Reading: "ヒガシ", Class: Surname, Cost: 2620},
+	{Surface: "岩田", Reading: "イワタ", Class: Surname, Cost: 2625},
+	{Surface: "土屋", Reading: "ツチヤ", Class: Surname, Cost: 2630},
+	{Surface: "川崎", Reading: "カワサキ", Class: Surname, Cost: 2635},
+	{Surface: "福島", Reading: "フクシマ", Class: Surname, Cost: 2640},
+	{Surface: "本田", Reading: "ホンダ", Class: Surname, Cost: 2645},
+	{Surface: "辻", Reading: "ツジ", Class: Surname, Cost: 2650},
+	{Surface: "樋口", Reading: "ヒグチ", Class: Surname, Cost: 2655},
+	{Surface: "秋山", Reading: "アキヤマ", Class: Surname, Cost: 2660},
+	{Surface: "田所", Reading: "タドコロ", Class: Surname, Cost: 2665},
+	{Surface: "田口", Reading: "タグチ", Class: Surname, Cost: 2670},
+	{Surface: "永井", Reading: "ナガイ", Class: Surname, Cost: 2675},
+	{Surface: "山中", Reading: "ヤマナカ", Class: Surname, Cost: 2680},
+	{Surface: "中西", Reading: "ナカニシ", Class: Surname, Cost: 2685},
+	{Surface: "吉村", Reading: "ヨシムラ", Class: Surname, Cost: 2690},
+	{Surface: "川上", Reading: "カワカミ", Class: Surname, Cost: 2695},
+	{Surface: "大橋", Reading: "オオハシ", Class: Surname, Cost: 2700},
+	{Surface: "石原", Reading: "イシハラ", Class: Surname, Cost: 2705},
+	{Surface: "松岡", Reading: "マツオカ", Class: Surname, Cost: 2710},
+	{Surface: "浜田", Reading: "ハマダ", Class: Surname, Cost: 2715},
+	{Surface: "馬場", Reading: "ババ", Class: Surname, Cost: 2720},
+	{Surface: "森本", Reading: "モリモト", Class: Surname, Cost: 2725},
+	{Surface: "矢野", Reading: "ヤノ", Class: Surname, Cost: 2730},
+	{Surface: "浅野", Reading: "アサノ", Class: Surname, Cost: 2735},
+	{Surface: "星野", Reading: "ホシノ", Class: Surname, Cost: 2740},
+	{Surface: "松下", Reading: "マツシタ", Class: Surname, Cost: 2745},
+	{Surface: "大久保", Reading: "オオクボ", Class: Surname, Cost: 2750},
+	{Surface: "吉岡", Reading: "ヨシオカ", Class: Surname, Cost: 2755},
+	{Surface: "小池", Reading: "コイケ", Class: Surname, Cost: 2760},
+	{Surface: "野田", Reading: "ノダ", Class: Surname, Cost: 2765},
+	{Surface: "荒木", Reading: "アラキ", Class: Surname, Cost: 2770},
+	{Surface: "大谷", Reading: "オオタニ", Class: Surname, Cost: 2775},
+	{Surface: "内藤", Reading: "ナイトウ", Class: Surname, Cost: 2780},
+	{Surface: "松浦", Reading: "マツウラ", Class: Surname, Cost: 2785},
+	{Surface: "熊谷", Reading: "クマガイ", Class: Surname, Cost: 2790},
+	{Surface: "黒田", Reading: "クロダ", Class: Surname, Cost: 2795},
+	{Surface: "尾崎", Reading: "オザキ", Class: Surname, Cost: 2800},
+	{Surface: "永田", Reading: "ナガタ", Class: Surname, Cost: 2805},
+	{Surface: "川村", Reading: "カワムラ", Class: Surname, Cost: 2810},
+	{Surface: "望月", Reading: "モチヅキ", Class: Surname, Cost: 2815},
+	{Surface: "田辺", Reading: "タナベ", Class: Surname, Cost: 2820},
+	{Surface: "松村", Reading: "マツムラ", Class: Surname, Cost: 2825},
+	{Surface: "荒井", Reading: "アライ", Class: Surname, Cost: 2830},
+	{Surface: "小田", Reading: "オダ", Class: Surname, Cost: 2835},
+	{Surface: "大川", Reading: "オオカワ", Class: Surname, Cost: 2840},
+	{Surface: "福井", Reading: "フクイ", Class: Surname, Cost: 2845},
+	{Surface: "高山", Reading: "タカヤマ", Class: Surname, Cost: 2850},
+	{Surface: "松原", Reading: "マツバラ", Class: Surname, Cost: 2855},
+	{Surface: "岩本", Reading: "イワモト", Class: Surname, Cost: 2860},
+	{Surface: "片山", Reading: "カタヤマ", Class: Surname, Cost: 2865},
+	{Surface: "早川", Reading: "ハヤカワ", Class: Surname, Cost: 2870},
+	{Surface: "宮田", Reading: "ミヤタ", Class: Surname, Cost: 2875},
+	{Surface: "本間", Reading: "ホンマ", Class: Surname, Cost: 2880},
+	{Surface: "桑原", Reading: "クワハラ", Class: Surname, Cost: 2885},
+	{Surface: "大島", Reading: "オオシマ", Class: Surname, Cost: 2890},
+	{Surface: "杉浦", Reading: "スギウラ", Class: Surname, Cost: 2895},
+	{Surface: "菅野", Reading: "スガノ", Class: Surname, Cost: 2900},
+	{Surface: "西山", Reading: "ニシヤマ", Class: Surname, Cost: 2905},
+	{Surface: "平井", Reading: "ヒライ", Class: Surname, Cost: 2910},
+	{Surface: "関口", Reading: "セキグチ", Class: Surname, Cost: 2915},
+	{Surface: "堀", Reading: "ホリ", Class: Surname, Cost: 2920},
+	{Surface: "中尾", Reading: "ナカオ", Class: Surname, Cost: 2925},
+	{Surface: "大石", Reading: "オオイシ", Class: Surname, Cost: 2930},
+	{Surface: "宮下", Reading: "ミヤシタ", Class: Surname, Cost: 2935},
+	{Surface: "上原", Reading: "ウエハラ", Class: Surname, Cost: 2940},
+	{Surface: "須藤", Reading: "スドウ", Class: Surname, Cost: 2945},
+	{Surface: "小西", Reading: "コニシ", Class: Surname, Cost: 2950},
+	{Surface: "川田", Reading: "カワタ", Class: Surname, Cost: 2955},
+	{Surface: "萩原", Reading: "ハギワラ", Class: Surname, Cost: 2960},
+	{Surface: "三宅", Reading: "ミヤケ", Class: Surname, Cost: 2965},
+	{Surface: "栗原", Reading: "クリハラ", Class: Surname, Cost: 2970},
+	{Surface: "吉野", Reading: "ヨシノ", Class: Surname, Cost: 2975},
+	{Surface: "荒川", Reading: "アラカワ", Class: Surname, Cost: 2980},
+	{Surface: "田島", Reading: "タジマ", Class: Surname, Cost: 2985},
+	{Surface: "松永", Reading: "マツナガ", Class: Surname, Cost: 2990},
+	{Surface: "小澤", Reading: "オザワ", Class: Surname, Cost: 2995},
+	{Surface: "小沢", Reading: "オザワ", Class: Surname, Cost: 3000},
+	{Surface: "大竹", Reading: "オオタケ", Class: Surname, Cost: 3005},
+	{Surface: "岡崎", Reading: "オカザキ", Class: Surname, Cost: 3010},
+	{Surface: "川島", Reading: "カワシマ", Class: Surname, Cost: 3015},
+	{Surface: "藤川", Reading: "フジカワ", Class: Surname, Cost: 3020},
+	{Surface: "北川", Reading: "キタガワ", Class: Surname, Cost: 3025},
+	{Surface: "山岸", Reading: "ヤマギシ", Class: Surname, Cost: 3030},
+
+	// 名
+	{Surface: "太郎", Reading: "タロウ", Class: GivenName, Cost: 2000},
+	{Surface: "次郎", Reading: "ジロウ", Class: GivenName, Cost: 2005},
+	{Surface: "二郎", Reading: "ジロウ", Class: GivenName, Cost: 2010},
+	{Surface: "三郎", Reading: "サブロウ", Class: GivenName, Cost: 2015},
+	{Surface: "一郎", Reading: "イチロウ", Class: GivenName, Cost: 2020},
+	{Surface: "四郎", Reading: "シロウ", Class: GivenName, Cost: 2025},
+	{Surface: "五郎", Reading: "ゴロウ", Class: GivenName, Cost: 2030},
+	{Surface: "健太", Reading: "ケンタ", Class: GivenName, Cost: 2035},
+	{Surface: "翔太", Reading: "ショウタ", Class: GivenName, Cost: 2040},
+	{Surface: "大輔", Reading: "ダイスケ", Class: GivenName, Cost: 2045},
+	{Surface: "拓也", Reading: "タクヤ", Class: GivenName, Cost: 2050},
+	{Surface: "直樹", Reading: "ナオキ", Class: GivenName, Cost: 2055},
+	{Surface: "和也", Reading: "カズヤ", Class: GivenName, Cost: 2060},
+	{Surface: "達也", Reading: "タツヤ", Class: GivenName, Cost: 2065},
+	{Surface: "健一", Reading: "ケンイチ", Class: GivenName, Cost: 2070},
+	{Surface: "誠", Reading: "マコト", Class: GivenName, Cost: 2075},
+	{Surface: "浩", Reading: "ヒロシ", Class: GivenName, Cost: 2080},
+	{Surface: "隆", Reading: "タカシ", Class: GivenName, Cost: 2085},
+	{Surface: "学", Reading: "マナブ", Class: GivenName, Cost: 2090},
+	{Surface: "剛", Reading: "ツヨシ", Class: GivenName, Cost: 2095},
+	{Surface: "翔", Reading: "ショウ", Class: GivenName, Cost: 2100},
+	{Surface: "蓮", Reading: "レン", Class: GivenName, Cost: 2105},
+	{Surface: "大翔", Reading: "ヒロト", Class: GivenName, Cost: 2110},
+	{Surface: "悠真", Reading: "ユウマ", Class: GivenName, Cost: 2115},
+	{Surface: "陽翔", Reading: "ハルト", Class: GivenName, Cost: 2120},
+	{Surface: "湊", Reading: "ミナト", Class: GivenName, Cost: 2125},
+	{Surface: "健", Reading: "ケン", Class: GivenName, Cost: 2130},
+	{Surface: "修", Reading: "オサム", Class: GivenName, Cost: 2135},
+	{Surface: "勇気", Reading: "ユウキ", Class: GivenName, Cost: 2140},
+	{Surface: "翼", Reading: "ツバサ", Class: GivenName, Cost: 2145},
+	{Surface: "花子", Reading: "ハナコ", Class: GivenName, Cost: 2150},
+	{Surface: "陽子", Reading: "ヨウコ", Class: GivenName, Cost: 2155},
+	{Surface: "恵子", Reading: "ケイコ", Class: GivenName, Cost: 2160},
+	{Surface: "裕子", Reading: "ユウコ", Class: GivenName, Cost: 2165},
+	{Surface: "京子", Reading: "キョウコ", Class: GivenName, Cost: 2170},
+	{Surface: "幸子", Reading: "サチコ", Class: GivenName, Cost: 2175},
+	{Surface: "洋子", Reading: "ヨウコ", Class: GivenName, Cost: 2180},
+	{Surface: "由美", Reading: "ユミ", Class: GivenName, Cost: 2185},
+	{Surface: "真由美", Reading: "マユミ", Class: GivenName, Cost: 2190},
+	{Surface: "美咲", Reading: "ミサキ", Class: GivenName, Cost: 2195},
+	{Surface: "愛", Reading: "アイ", Class: GivenName, Cost: 2200},
+	{Surface: "結衣", Reading: "ユイ", Class: GivenName, Cost: 2205},
+	{Surface: "陽菜", Reading: "ヒナ", Class: GivenName, Cost: 2210},
+	{Surface: "葵", Reading: "アオイ", Class: GivenName, Cost: 2215},
+	{Surface: "さくら", Reading: "サクラ", Class: GivenName, Cost: 2220},
+	{Surface: "美穂", Reading: "ミホ", Class: GivenName, Cost: 2225},
+	{Surface: "明美", Reading: "アケミ", Class: GivenName, Cost: 2230},
+	{Surface: "直美", Reading: "ナオミ", Class: GivenName, Cost: 2235},
+	{Surface: "智子", Reading: "トモコ", Class: GivenName, Cost: 2240},
+	{Surface: "久美子", Reading: "クミコ", Class: GivenName, Cost: 2245},
+	{Surface: "優子", Reading: "ユウコ", Class: GivenName, Cost: 2250},
+	{Surface: "由紀", Reading: "ユキ", Class: GivenName, Cost: 2255},
+	{Surface: "彩", Reading: "アヤ", Class: GivenName, Cost: 2260},
+	{Surface: "舞", Reading: "マイ", Class: GivenName, Cost: 2265},
+	{Surface: "愛子", Reading: "アイコ", Class: GivenName, Cost: 2270},
+	{Surface: "香織", Reading: "カオリ", Class: GivenName, Cost: 2275},
+	{Surface: "麻衣", Reading: "マイ", Class: GivenName, Cost: 2280},
+	{Surface: "沙織", Reading: "サオリ", Class: GivenName, Cost: 2285},
+	{Surface: "千尋", Reading: "チヒロ", Class: GivenName, Cost: 2290},
+	{Surface: "莉子", Reading: "リコ", Class: GivenName, Cost: 2295},
+	{Surface: "大輝", Reading: "ダイキ", Class: GivenName, Cost: 2300},
+	{Surface: "大樹", Reading: "ダイキ", Class: GivenName, Cost: 2305},
+	{Surface: "悠斗", Reading: "ユウト", Class: GivenName, Cost: 2310},
+	{Surface: "悠人", Reading: "ユウト", Class: GivenName, Cost: 2315},
+	{Surface: "陸", Reading: "リク", Class: GivenName, Cost: 2320},
+	{Surface: "蒼", Reading: "アオイ", Class: GivenName, Cost: 2325},
+	{Surface: "樹", Reading: "イツキ", Class: GivenName, Cost: 2330},
+	{Surface: "颯太", Reading: "ソウタ", Class: GivenName, Cost: 2335},
+	{Surface: "陽太", Reading: "ヨウタ", Class: GivenName, Cost: 2340},
+	{Surface: "大和", Reading: "ヤマト", Class: GivenName, Cost: 2345},
+	{Surface: "拓海", Reading: "タクミ", Class: GivenName, Cost: 2350},
+	{Surface: "海斗", Reading: "カイト", Class: GivenName, Cost: 2355},
+	{Surface: "優斗", Reading: "ユウト", Class: GivenName, Cost: 2360},
+	{Surface: "健二", Reading: "ケンジ", Class: GivenName, Cost: 2365},
+	{Surface: "健太郎", Reading: "ケンタロウ", Class: GivenName, Cost: 2370},
+	{Surface: "浩二", Reading: "コウジ", Class: GivenName, Cost: 2375},
+	{Surface: "浩一", Reading: "コウイチ", Class: GivenName, Cost: 2380},
+	{Surface: "雄一", Reading: "ユウイチ", Class: GivenName, Cost: 2385},
+	{Surface: "裕太", Reading: "ユウタ", Class: GivenName, Cost: 2390},
+	{Surface: "亮", Reading: "リョウ", Class: GivenName, Cost: 2395},
+	{Surface: "亮太", Reading: "リョウタ", Class: GivenName, Cost: 2400},
+	{Surface: "涼太", Reading: "リョウタ", Class: GivenName, Cost: 2405},
+	{Surface: "翔平", Reading: "ショウヘイ", Class: GivenName, Cost: 2410},
+	{Surface: "俊介", Reading: "シュンスケ", Class: GivenName, Cost: 2415},
+	{Surface: "雄太", Reading: "ユウタ", Class: GivenName, Cost: 2420},
+	{Surface: "康介", Reading: "コウスケ", Class: GivenName, Cost: 2425},
+	{Surface: "大介", Reading: "ダイスケ", Class: GivenName, Cost: 2430},
+	{Surface: "洋平", Reading: "ヨウヘイ", Class: GivenName, Cost: 2435},
+	{Surface: "直人", Reading: "ナオト", Class: GivenName, Cost: 2440},
+	{Surface: "秀樹", Reading: "ヒデキ", Class: GivenName, Cost: 2445},
+	{Surface: "正樹", Reading: "マサキ", Class: GivenName, Cost: 2450},
+	{Surface: "雅人", Reading: "マサト", Class: GivenName, Cost: 2455},
+	{Surface: "博", Reading: "ヒロシ", Class: GivenName, Cost: 2460},
+	{Surface: "茂", Reading: "シゲル", Class: GivenName, Cost: 2465},
+	{Surface: "清", Reading: "キヨシ", Class: GivenName, Cost: 2470},
+	{Surface: "実", Reading: "ミノル", Class: GivenName, Cost: 2475},
+	{Surface: "勝", Reading: "マサル", Class: GivenName, Cost: 2480},
+	{Surface: "進", Reading: "ススム", Class: GivenName, Cost: 2485},
+	{Surface: "豊", Reading: "ユタカ", Class: GivenName, Cost: 2490},
+	{Surface: "明", Reading: "アキラ", Class: GivenName, Cost: 2495},
+	{Surface: "哲也", Reading: "テツヤ", Class: GivenName, Cost: 2500},
+	{Surface: "信也", Reading: "シンヤ", Class: GivenName, Cost: 2505},
+	{Surface: "智也", Reading: "トモヤ", Class: GivenName, Cost: 2510},
+	{Surface: "和彦", Reading: "カズヒコ", Class: GivenName, Cost: 2515},
+	{Surface: "正人", Reading: "マサト", Class: GivenName, Cost: 2520},
+	{Surface: "悟", Reading: "サトル", Class: GivenName, Cost: 2525},
+	{Surface: "聡", Reading: "サトシ", Class: GivenName, Cost: 2530},
+	{Surface: "淳", Reading: "ジュン", Class: GivenName, Cost: 2535},
+	{Surface: "純一", Reading: "ジュンイチ", Class: GivenName, Cost: 2540},
+	{Surface: "美紀", Reading: "ミキ", Class: GivenName, Cost: 2545},
+	{Surface: "美香", Reading: "ミカ", Class: GivenName, Cost: 2550},
+	{Surface: "真美", Reading: "マミ", Class: GivenName, Cost: 2555},
+	{Surface: "恵", Reading: "メグミ", Class: GivenName, Cost: 2560},
+	{Surface: "恵美", Reading: "エミ", Class: GivenName, Cost: 2565},
+	{Surface: "理恵", Reading: "リエ", Class: GivenName, Cost: 2570},
+	{Surface: "明子", Reading: "アキコ", Class: GivenName, Cost: 2575},
+	{Surface: "和子", Reading: "カズコ", Class: GivenName, Cost: 2580},
+	{Surface: "節子", Reading: "セツコ", Class: GivenName, Cost: 2585},
+	{Surface: "弘子", Reading: "ヒロコ", Class: GivenName, Cost: 2590},
+	{Surface: "正子", Reading: "マサコ", Class: GivenName, Cost: 2595},
+	{Surface: "美智子", Reading: "ミチコ", Class: GivenName, Cost: 2600},
+	{Surface: "由美子", Reading: "ユミコ", Class: GivenName, Cost: 2605},
+	{Surface: "典子", Reading: "ノリコ", Class: GivenName, Cost: 2610},
+	{Surface: "直子", Reading: "ナオコ", Class: GivenName, Cost: 2615},
+	{Surface: "順子", Reading: "ジュンコ", Class: GivenName, Cost: 2620},
+	{Surface: "純子", Reading: "ジュンコ", Class: GivenName, Cost: 2625},
+	{Surface: "瞳", Reading: "ヒトミ", Class: GivenName, Cost: 2630},
+	{Surface: "遥", Reading: "ハルカ", Class: GivenName, Cost: 2635},
+	{Surface: "美月", Reading: "ミヅキ", Class: GivenName, Cost: 2640},
+	{Surface: "七海", Reading: "ナナミ", Class: GivenName, Cost: 2645},
+	{Surface: "凛", Reading: "リン", Class: GivenName, Cost: 2650},
+	{Surface: "結菜", Reading: "ユイナ", Class: GivenName, Cost: 2655},
+	{Surface: "芽依", Reading: "メイ", Class: GivenName, Cost: 2660},
+	{Surface: "彩花", Reading: "アヤカ", Class: GivenName, Cost: 2665},
+	{Surface: "美優", Reading: "ミユ", Class: GivenName, Cost: 2670},
+	{Surface: "花音", Reading: "カノン", Class: GivenName, Cost: 2675},
+	{Surface: "杏", Reading: "アン", Class: GivenName, Cost: 2680},
+	{Surface: "奈々", Reading: "ナナ", Class: GivenName, Cost: 2685},
+	{Surface: "麻美", Reading: "アサミ", Class: GivenName, Cost: 2690},
+	{Surface: "亜美", Reading: "アミ", Class: GivenName, Cost: 2695},
+	{Surface: "加奈子", Reading: "カナコ", Class: GivenName, Cost: 2700},
+	{Surface: "玲奈", Reading: "レイナ", Class: GivenName, Cost: 2705},
+	{Surface: "桃子", Reading: "モモコ", Class: GivenName, Cost: 2710},
+	{Surface: "陽葵", Reading: "ヒマリ", Class: GivenName, Cost: 2715},
+	{Surface: "紬", Reading: "ツムギ", Class: GivenName, Cost: 2720},
+	{Surface: "咲", Reading: "サキ", Class: GivenName, Cost: 2725},
+
+	// 名詞
+	{Surface: "株式会社", Reading: "カブシキガイシャ", Class: Noun, Cost: 2500},
+	{Surface: "会社", Reading: "カイシャ", Class: Noun, Cost: 2510},
+	{Surface: "東京", Reading: "トウキョウ", Class: Noun, Cost: 2520},
+	{Surface: "大阪", Reading: "オオサカ", Class: Noun, Cost: 2530},
+	{Surface: "京都", Reading: "キョウト", Class: Noun, Cost: 2540},
+	{Surface: "日本", Reading: "ニホン", Class: Noun, Cost: 2550},
+	{Surface: "営業", Reading: "エイギョウ", Class: Noun, Cost: 2560},
+	{Surface: "部長", Reading: "ブチョウ", Class: Noun, Cost: 2570},
+	{Surface: "課長", Reading: "カチョウ", Class: Noun, Cost: 2580},
+	{Surface: "社長", Reading: "シャチョウ", Class: Noun, Cost: 2590},
+
+	// 助詞
+	{Surface: "は", Reading: "ハ", Class: Particle, Cost: 1000},
+	{Surface: "が", Reading: "ガ", Class: Particle, Cost: 1010},
+	{Surface: "の", Reading: "ノ", Class: Particle, Cost: 1020},
+	{Surface: "を", Reading: "ヲ", Class: Particle, Cost: 1030},
+	{Surface: "に", Reading: "ニ", Class: Particle, Cost: 1040},
+	{Surface: "へ", Reading: "ヘ", Class: Particle, Cost: 1050},
+	{Surface: "と", Reading: "ト", Class: Particle, Cost: 1060},
+	{Surface: "で", Reading: "デ", Class: Particle, Cost: 1070},
+	{Surface: "や", Reading: "ヤ", Class: Particle, Cost: 1080},
+	{Surface: "も", Reading: "モ", Class: Particle, Cost: 1090},
+	{Surface: "から", Reading: "カラ", Class: Particle, Cost: 1100},
+	{Surface: "まで", Reading: "マデ", Class: Particle, Cost: 1110},
+	{Surface: "より", Reading: "ヨリ", Class: Particle, Cost: 1120},
+	{Surface: "か", Reading: "カ", Class: Particle, Cost: 1130},
+
+	// 接尾辞
+	{Surface: "さん", Reading: "サン", Class: Suffix, Cost: 1000},
+	{Surface: "様", Reading: "サマ", Class: Suffix, Cost: 1010},
+	{Surface: "さま", Reading: "サマ", Class: Suffix, Cost: 1020},
+	{Surface: "君", Reading: "クン", Class: Suffix, Cost: 1030},
+	{Surface: "くん", Reading: "クン", Class: Suffix, Cost: 1040},
+	{Surface: "ちゃん", Reading: "チャン", Class: Suffix, Cost: 1050},
+	{Surface: "氏", Reading: "シ", Class: Suffix, Cost: 1060},
+	{Surface: "殿", Reading: "ドノ", Class: Suffix, Cost: 1070},
+}
@@ -0,0 +1,324 @@
+// Package morph は辞書を使用した日本語の形態素解析を行う
+//
+// 辞書は Go のソースとして埋め込んでいるため、外部のファイルやサービスを使用せずに動作する。
+// 辞書にない語は文字種(漢字、ひらがな、カタカナ、英字、数字)ごとに未知語として扱う。
+// 文字列の分割は、語ごとのコストと品詞の連接コストの合計が最小となる分割をViterbiアルゴリズムで求める。
+package morph
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Class は語の品詞
+type Class int
+
+// 品詞の一覧
+const (
+	// Unknown は辞書にない語
+	Unknown Class = iota
+	// Noun は一般名詞
+	Noun
+	// Surname は姓
+	Surname
+	// GivenName は名
+	GivenName
+	// Particle は助詞
+	Particle
+	// Suffix は「さん」などの接尾辞
+	Suffix
+	// Space は空白
+	Space
+	// Symbol は記号
+	Symbol
+
+	// bos は文頭を表す品詞
+	bos
+)
+
+// Entry は辞書の見出し語
+type Entry struct {
+	Surface string
+	// Reading はカタカナの読み
+	Reading string
+	Class   Class
+	// Cost は語の出現しにくさ。小さいほどその語として分割されやすい
+	Cost int
+}
+
+// Morpheme は形態素解析の結果の語
+type Morpheme struct {
+	Surface string
+	// Reading はカタカナの読み。未知語の漢字など、読みが分からない場合は空文字
+	Reading string
+	Class   Class
+}
+
+// Dictionary は形態素解析に使用する辞書
+type Dictionary struct {
+	entries map[string][]Entry
+	// maxLen は見出し語の最大の文字数
+	maxLen int
+}
+
+// kanaCost は姓と名を読みの仮名で表記した語に加えるコスト
+const kanaCost = 500
+
+// NewDictionary は見出し語の一覧から辞書を作成する
+//
+// 姓と名は「たなか」や「タナカ」のように仮名で入力されることもあるため、読みのひらがなとカタカナでも見出し語とする。
+func NewDictionary(entries []Entry) *Dictionary {
+	d := &Dictionary{entries: make(map[string][]Entry, len(entries))}
+	for _, e := range entries {
+		d.add(e)
+		if e.Class != Surname && e.Class != GivenName {
+			continue
+		}
+		for _, surface := range []string{hiragana(e.Reading), e.Reading} {
+			if surface != e.Surface {
+				d.add(Entry{Surface: surface, Reading: e.Reading, Class: e.Class, Cost: e.Cost + kanaCost})
+			}
+		}
+	}
+	return d
+}
+
+func (d *Dictionary) add(e Entry) {
+	for _, x := range d.entries[e.Surface] {
+		// 同じ読みの異なる表記から作成した仮名の語は、コストの小さいものだけを残す
+		if x.Class == e.Class && x.Reading == e.Reading {
+			return
+		}
+	}
+	d.entries[e.Surface] = append(d.entries[e.Surface], e)
+	if n := len([]rune(e.Surface)); n > d.maxLen {
+		d.maxLen = n
+	}
+}
+
+var defaultDictionary = NewDictionary(defaultEntries)
+
+// Tokenizer は形態素解析で文字列を語に分割する
+type Tokenizer struct {
+	dict *Dictionary
+}
+
+// New は埋め込みの辞書を使用する Tokenizer を返す
+func New() *Tokenizer {
+	return &Tokenizer{dict: defaultDictionary}
+}
+
+// NewWithDictionary は指定した辞書を使用する Tokenizer を返す
+func NewWithDictionary(dict *Dictionary) *Tokenizer {
+	return &Tokenizer{dict: dict}
+}
+
+// Tokenize は文字列を語に分割し、空白と記号を除いた語を返す
+func (t *Tokenizer) Tokenize(s string) []string {
+	morphemes := t.Analyze(s)
+	tokens := make([]string, 0, len(morphemes))
+	for _, m := range morphemes {
+		if m.Class == Space || m.Class == Symbol {
+			continue
+		}
+		tokens = append(tokens, m.Surface)
+	}
+	return tokens
+}
+
+// QueryTokens は検索ワードを Tokenize と同じ規則で語に分割する
+func (t *Tokenizer) QueryTokens(s string) []string {
+	return t.Tokenize(s)
+}
+
+// node はラティス上の語の候補
+type node struct {
+	m    Morpheme
+	cost int
+	// total は文頭からこの語までの最小のコスト
+	total int
+	prev  *node
+}
+
+// Analyze は文字列を形態素解析し、語の一覧を返す
+func (t *Tokenizer) Analyze(s string) []Morpheme {
+	runes := []rune(s)
+	if len(runes) == 0 {
+		return []Morpheme{}
+	}
+
+	// ends[i] は i 文字目で終わる語の候補
+	ends := make([][]*node, len(runes)+1)
+	ends[0] = []*node{{m: Morpheme{Class: bos}}}
+	for i := range runes {
+		if len(ends[i]) == 0 {
+			continue
+		}
+		for _, n := range t.candidates(runes, i) {
+			end := i + len([]rune(n.m.Surface))
+			// 直前の語との連接コストを含めて、コストが最小となる直前の語を選択する
+			for _, p := range ends[i] {
+				total := p.total + connectionCost(p.m.Class, n.m.Class) + n.cost
+				if n.prev == nil || total < n.total {
+					n.total, n.prev = total, p
+				}
+			}
+			ends[end] = append(ends[end], n)
+		}
+	}
+
+	var last *node
+	for _, n := range ends[len(runes)] {
+		if last == nil || n.total < last.total {
+			last = n
+		}
+	}
+
+	var ret []Morpheme
+	for n := last; n.m.Class != bos; n = n.prev {
+		ret = append(ret, n.m)
+	}
+	for i, j := 0, len(ret)-1; i < j; i, j = i+1, j-1 {
+		ret[i], ret[j] = ret[j], ret[i]
+	}
+	return ret
+}
+
+// candidates は i 文字目から始まる語の候補を列挙する
+func (t *Tokenizer) candidates(runes []rune, i int) []*node {
+	var nodes []*node
+	for l := 1; l <= t.dict.maxLen && i+l <= len(runes); l++ {
+		for _, e := range t.dict.entries[string(runes[i:i+l])] {
+			nodes = append(nodes, &node{
+				m:    Morpheme{Surface: e.Surface, Reading: e.Reading, Class: e.Class},
+				cost: e.Cost,
+			})
+		}
+	}
+	return append(nodes, unknownCandidates(runes, i)...)
+}
+
+// 文字種
+const (
+	kindOther = iota
+	kindSpace
+	kindKanji
+	kindHiragana
+	kindKatakana
+	kindAlpha
+	kindDigit
+)
+
+func charKind(r rune) int {
+	switch {
+	case unicode.IsSpace(r):
+		return kindSpace
+	case unicode.Is(unicode.Han, r) || r == '々':
+		return kindKanji
+	case unicode.Is(unicode.Hiragana, r):
+		return kindHiragana
+	case unicode.Is(unicode.Katakana, r) || r == 'ー':
+		return kindKatakana
+	case unicode.IsLetter(r):
+		return kindAlpha
+	case unicode.IsDigit(r):
+		return kindDigit
+	}
+	return kindOther
+}
+
+// 未知語のコスト
+const (
+	unknownCost        = 4000
+	unknownCostPerChar = 1500
+	// unknownCostPerKana は仮名が続く範囲全体を未知語とする場合の1文字あたりのコスト
+	// 仮名で入力された姓名を、1語の未知語とするより辞書の語に分割しやすくする
+	unknownCostPerKana = 300
+)
+
+// unknownCandidates は i 文字目から始まる未知語の候補を列挙する
+//
+// カタカナ、英字、数字、空白は同じ文字種が続く範囲を1語とする。
+// 漢字とひらがなは辞書の語と組み合わせて分割できるよう、続く範囲のうち1文字から3文字までも候補とする。
+func unknownCandidates(runes []rune, i int) []*node {
+	kind := charKind(runes[i])
+	if kind == kindOther {
+		return []*node{{m: Morpheme{Surface: string(runes[i]), Class: Symbol}}}
+	}
+
+	end := i + 1
+	for end < len(runes) && charKind(runes[end]) == kind {
+		end++
+	}
+
+	newNode := func(l int, cost int) *node {
+		surface := string(runes[i : i+l])
+		n := &node{m: Morpheme{Surface: surface, Reading: reading(surface, kind), Class: Unknown}, cost: cost}
+		if kind == kindSpace {
+			n.m.Class, n.cost = Space, 0
+		}
+		return n
+	}
+
+	// 漢字は1文字ずつ意味を持つため、続く範囲全体の場合も文字数に応じたコストとする
+	cost := unknownCost
+	switch kind {
+	case kindKanji:
+		cost += unknownCostPerChar * (end - i)
+	case kindHiragana, kindKatakana:
+		cost += unknownCostPerKana * (end - i)
+	}
+	nodes := []*node{newNode(end-i, cost)}
+	if kind == kindKanji || kind == kindHiragana {
+		for l := 1; l <= 3 && i+l < end; l++ {
+			nodes = append(nodes, newNode(l, unknownCost+unknownCostPerChar*l))
+		}
+	}
+	return nodes
+}
+
+// reading は未知語の読みを文字種から求める
+func reading(surface string, kind int) string {
+	switch kind {
+	case kindKatakana:
+		return surface
+	case kindHiragana:
+		return strings.Map(func(r rune) rune {
+			if r >= 'ぁ' && r <= 'ゖ' {
+				return r + 'ァ' - 'ぁ'
+			}
+			return r
+		}, surface)
+	}
+	return ""
+}
+
+// hiragana はカタカナをひらがなに変換する
+func hiragana(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'ァ' && r <= 'ヶ' {
+			return r - ('ァ' - 'ぁ')
+		}
+		return r
+	}, s)
+}
+
+// connectionCost は品詞の連接コストを返す
+func connectionCost(left, right Class) int {
+	switch {
+	case left == Surname && right == GivenName:
+		// 「田中太郎」のような姓名の並び
+		return -1000
+	case right == Suffix && (left == Surname || left == GivenName || left == Noun):
+		return -500
+	case right == Particle && (left == bos || left == Particle || left == Space):
+		// 文頭や助詞の直後の助詞は少ない
+		return 2000
+	case right == Suffix:
+		return 2000
+	case left == Unknown && right == Unknown:
+		// 未知語を細かく分割しすぎないようにする
+		return 1000
+	}
+	return 0
+}
@@ -0,0 +1,61 @@
+package morph_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/ryutah/gaego-search-sample/foosearch"
+	"github.com/ryutah/gaego-search-sample/foosearch/morph"
+)
+
+func TestTokenize(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []string
+	}{
+		{"田中太郎", []string{"田中", "太郎"}},
+		{"佐藤花子", []string{"佐藤", "花子"}},
+		{"鈴木一郎さん", []string{"鈴木", "一郎", "さん"}},
+		{"山田 陽菜", []string{"山田", "陽菜"}},
+		{"長谷川真由美", []string{"長谷川", "真由美"}},
+		{"タナカタロウ", []string{"タナカ", "タロウ"}},
+		{"営業部長の高橋", []string{"営業", "部長", "の", "高橋"}},
+	}
+	tokenizer := morph.New()
+	for _, tt := range tests {
+		if got := tokenizer.Tokenize(tt.in); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Tokenize(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+// 検索時と同じく、カタカナをひらがなに統一してから分割した場合も姓と名に分割できる
+func TestTokenizeKana(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []string
+	}{
+		{"たなかたろう", []string{"たなか", "たろう"}},
+		{"タナカタロウ", []string{"たなか", "たろう"}},
+		{"サトウハナコ", []string{"さとう", "はなこ"}},
+		{"すずきさん", []string{"すずき", "さん"}},
+	}
+	n := &foosearch.Normalizer{Fold: true, Kana: true}
+	tokenizer := morph.New()
+	for _, tt := range tests {
+		if got := tokenizer.Tokenize(n.Normalize(tt.in)); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Tokenize(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestAnalyzeReading(t *testing.T) {
+	got := morph.New().Analyze("田中たろう")
+	want := []morph.Morpheme{
+		{Surface: "田中", Reading: "タナカ", Class: morph.Surname},
+		{Surface: "たろう", Reading: "タロウ", Class: morph.GivenName},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,427 @@
+package foosearch
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/net/context"
+	"google.golang.org/appengine/log"
+)
+
+// DefaultNGramSize は文字数の指定がない場合のNGramの文字数
+const DefaultNGramSize = 2
+
+// DefaultMaxNGramFilters は1回の検索でクエリに指定するトークンの条件の件数の上限
+const DefaultMaxNGramFilters = 8
+
+// NGramSizes は項目ごとのNGramの文字数。0の場合は DefaultNGramSize を使用する
+type NGramSizes struct {
+	// All は `q` パラメータで全項目を検索するためのトークンの文字数
+	All        int
+	FamilyName int
+	GivenName  int
+	Email      int
+}
+
+// NGramSearcher はNGramでトークナイズした文字列を検索インデックスとして全文検索を行う
+//
+// 指定した文字数のNGramに加えてUnigramもインデックスとして保存しておき、
+// NGramの文字数に満たない検索ワードはUnigramで検索する。
+// Tokenizer を指定した場合は、NGramの代わりにそのトークンを検索インデックスとする。
+// Normalizer を指定した場合は、インデックスの作成前と検索前に文字列を正規化する。
+//
+// トークンのAND条件では、トークンが別の位置や異なる順序で含まれている場合にも一致してしまうため、
+// 候補となったFooは実際の文字列に検索ワードが含まれているかを検証してから返す。
+//
+// 検索ワードが長い場合はトークンの条件が多くなりすぎるため、クエリに指定するのは MaxFilters 件までとする。
+// Stats が設定されている場合は件数の少ない(絞り込み効果の高い)トークンを優先して指定する。
+// クエリに指定しなかったトークンは、候補の検証で文字列が含まれていることを確認する際にあわせて確認される。
+//
+// Scoring を指定した場合は、検索結果をキーの順ではなく検索ワードとの関連度の高い順に返す。
+// 並び順が指定された場合は、Scoring より並び順を優先する。
+// Query.Fuzzy を指定した場合は、検索ワードとの編集距離が指定した値以内の文字列を含むFooも検索する。
+type NGramSearcher struct {
+	Repository
+	Sizes NGramSizes
+	// Tokenizer は全項目で使用するトークナイザ。nil の場合は Sizes の文字数の NGramTokenizer を使用する
+	Tokenizer Tokenizer
+	// Normalizer はトークナイズ前に文字列を正規化する。nil の場合は正規化しない
+	Normalizer *Normalizer
+	// MaxScan は候補を検証する際に、1回の検索で読み込むエンティティの件数の上限
+	MaxScan int
+	// MaxFilters は1回の検索でクエリに指定するトークンの条件の件数の上限
+	MaxFilters int
+	// Stats はトークンごとの件数。nil の場合は検索ワードの先頭から順にトークンを指定する
+	Stats TokenStats
+	// Scoring は検索結果の順位付けの設定。nil の場合はキーの順に返す
+	Scoring *Scoring
+}
+
+// NewNGramSearcher は指定したリポジトリを検索対象とする NGramSearcher を返す
+func NewNGramSearcher(repo Repository) *NGramSearcher {
+	return &NGramSearcher{Repository: repo, MaxScan: DefaultMaxScan, MaxFilters: DefaultMaxNGramFilters}
+}
+
+// ngramField はトークンのプレフィックスと、その項目で使用するトークナイザ
+type ngramField struct {
+	prefix    string
+	tokenizer Tokenizer
+}
+
+func (s *NGramSearcher) fields() (all, family, given, email ngramField) {
+	return ngramField{prefix: "*", tokenizer: s.tokenizer(s.Sizes.All)},
+		ngramField{prefix: "f", tokenizer: s.tokenizer(s.Sizes.FamilyName)},
+		ngramField{prefix: "g", tokenizer: s.tokenizer(s.Sizes.GivenName)},
+		ngramField{prefix: "e", tokenizer: s.tokenizer(s.Sizes.Email)}
+}
+
+func (s *NGramSearcher) tokenizer(n int) Tokenizer {
+	if s.Tokenizer != nil {
+		return s.Tokenizer
+	}
+	return NGramTokenizer{N: n}
+}
+
+// emailField はメールアドレスのローカル部とドメインを検索するためのトークンを生成する
+var emailField = ngramField{prefix: "m", tokenizer: EmailTokenizer{}}
+
+func ngramSize(n int) int {
+	if n <= 0 {
+		return DefaultNGramSize
+	}
+	return n
+}
+
+// indexTokens はインデックスとして保存するプレフィックス付きのトークンを生成する
+func (f ngramField) indexTokens(str string) []string {
+	return appendWithPrefix(nil, f.prefix, f.tokenizer.Tokenize(str))
+}
+
+// queryTokens は検索ワードのプレフィックス付きのトークンを生成する
+func (f ngramField) queryTokens(str string) []string {
+	return appendWithPrefix(nil, f.prefix, f.tokenizer.QueryTokens(str))
+}
+
+// createNGram はFooの各項目のトークンを生成する
+// 読みが設定されている場合は、読みとそのローマ字表記のトークンも姓・名のトークンとする
+func (s *NGramSearcher) createNGram(foo *Foo) []string {
+	all, family, given, email := s.fields()
+	familyValues, givenValues, emailValues := searchableValues(s.Normalizer.normalizeFoo(foo))
+
+	var index []string
+	for _, values := range [][]string{familyValues, givenValues, emailValues} {
+		for _, str := range values {
+			index = append(index, all.indexTokens(str)...)
+		}
+	}
+	for _, str := range familyValues {
+		index = append(index, family.indexTokens(str)...)
+	}
+	for _, str := range givenValues {
+		index = append(index, given.indexTokens(str)...)
+	}
+	for _, str := range emailValues {
+		index = append(index, email.indexTokens(str)...)
+		index = append(index, emailField.indexTokens(str)...)
+	}
+
+	// 読みとローマ字表記で同じトークンが生成されることがあるため、重複を取り除いて保存する
+	return uniqueTokens(index)
+}
+
+// Search は `q` パラメータを全項目、それ以外を各項目に対する部分一致として検索する
+func (s *NGramSearcher) Search(ctx context.Context, q Query) (*Response, error) {
+	if q.Fuzzy < 0 || q.Fuzzy > MaxFuzzyDistance {
+		return nil, &InvalidQueryError{Reason: fmt.Sprintf("fuzzy must be between 0 and %d", MaxFuzzyDistance)}
+	}
+
+	// 各検索ワードを正規化し、プレフィックス付きでトークナイズ
+	// `q` パラメータの "local:" と "domain:" の語は、メールアドレスのローカル部とドメインのトークンで検索する
+	q = s.Normalizer.normalizeQuery(q)
+	text, terms := parseEmailTerms(q.Text)
+	q.Text = text
+	if q.Fuzzy > 0 {
+		words, err := s.fuzzyWords(q)
+		if err != nil {
+			return nil, err
+		}
+		// 区分付きの語のみの場合は、あいまい検索を行わない
+		if len(words) != 0 {
+			resp, err := s.fuzzyPage(ctx, q, words, terms)
+			if err != nil {
+				return nil, err
+			}
+			s.highlight(resp, q, terms)
+			return resp, nil
+		}
+	}
+
+	all, family, given, email := s.fields()
+	var tokens []string
+	tokens = append(tokens, all.queryTokens(q.Text)...)
+	tokens = append(tokens, family.queryTokens(q.FamilyName)...)
+	tokens = append(tokens, given.queryTokens(q.GivenName)...)
+	tokens = append(tokens, email.queryTokens(q.Email)...)
+	for _, t := range terms {
+		tokens = append(tokens, emailField.queryTokens(t)...)
+	}
+
+	// トークナイズされた検索条件をAND条件として追加していく
+	eq := NewEntityQuery()
+	for _, t := range s.selectTokens(ctx, uniqueTokens(tokens)) {
+		eq = eq.Filter("Search=", t)
+	}
+
+	match := func(f *Foo) bool {
+		nf := s.Normalizer.normalizeFoo(f)
+		return containsQuery(nf, q) && hasEmailTerms(nf.Email, terms)
+	}
+	var (
+		resp *Response
+		err  error
+	)
+	// Search プロパティ以外はインデックスを作成していないため、並び替える場合は候補を読み込んでメモリ上で並び替える
+	if s.Scoring != nil || len(q.Sort) != 0 {
+		resp, err = s.rankedPage(ctx, eq, q, match)
+	} else if resp, err = scanPage(ctx, s.Repository, eq, q.Limit, q.Cursor, maxScan(s.MaxScan), match); err == nil {
+		resp.Facets, err = scanFacets(ctx, s.Repository, []*EntityQuery{eq}, match, q.Facets)
+	}
+	if err != nil {
+		return nil, err
+	}
+	s.highlight(resp, q, terms)
+	return resp, nil
+}
+
+// highlight は検索ワードが出現する範囲を、項目ごとに強調して検索結果に設定する
+//
+// 検索ワードがそのまま含まれる項目はその範囲を強調し、含まれない場合(読みやローマ字表記で一致した場合など)は
+// 検索ワードのトークンが出現する範囲を強調する。
+func (s *NGramSearcher) highlight(resp *Response, q Query, terms []string) {
+	all, family, given, email := s.fields()
+	words := make(map[string][]highlightWord)
+	add := func(f ngramField, word string, fields ...string) {
+		if word == "" {
+			return
+		}
+		tokens := f.tokenizer.QueryTokens(word)
+		for _, field := range fields {
+			words[field] = append(words[field], highlightWord{word: word, tokens: tokens})
+		}
+	}
+	add(all, q.Text, highlightFields...)
+	add(family, q.FamilyName, "FamilyName", "FamilyNameKana")
+	add(given, q.GivenName, "GivenName", "GivenNameKana")
+	add(email, q.Email, "Email")
+	// 区分付きの語は区切られた語に完全一致しているため、区分を除いた語をそのまま強調する
+	for _, t := range terms {
+		t = strings.TrimPrefix(strings.TrimPrefix(t, LocalTermPrefix), DomainTermPrefix)
+		words["Email"] = append(words["Email"], highlightWord{word: t})
+	}
+
+	setHighlights(resp, s.Normalizer, words)
+}
+
+// selectTokens はクエリに指定するトークンを、件数の少ないものから MaxFilters 件まで選択する
+func (s *NGramSearcher) selectTokens(ctx context.Context, tokens []string) []string {
+	max := s.MaxFilters
+	if max <= 0 {
+		max = DefaultMaxNGramFilters
+	}
+	if len(tokens) <= max {
+		return tokens
+	}
+	if s.Stats == nil {
+		return tokens[:max]
+	}
+
+	counts, err := s.Stats.Counts(ctx, tokens)
+	if err != nil {
+		// 件数が取得できなくても検索結果は変わらないため、先頭から順に指定する
+		log.Warningf(ctx, "failed to get token stats; error: %#v", err)
+		return tokens[:max]
+	}
+	sort.SliceStable(tokens, func(i, j int) bool {
+		return counts[tokens[i]] < counts[tokens[j]]
+	})
+	return tokens[:max]
+}
+
+// uniqueTokens は重複したトークンを取り除く
+func uniqueTokens(tokens []string) []string {
+	var (
+		seen = make(map[string]bool, len(tokens))
+		ret  = make([]string, 0, len(tokens))
+	)
+	for _, t := range tokens {
+		if !seen[t] {
+			seen[t] = true
+			ret = append(ret, t)
+		}
+	}
+	return ret
+}
+
+// containsQuery はFooの実際の文字列(読みとローマ字表記を含む)に検索ワードが含まれているかを判定する
+func containsQuery(f *Foo, q Query) bool {
+	family, given, email := searchableValues(f)
+	if q.Text != "" &&
+		!containsAny(family, q.Text) &&
+		!containsAny(given, q.Text) &&
+		!containsAny(email, q.Text) {
+		return false
+	}
+	return containsAny(family, q.FamilyName) &&
+		containsAny(given, q.GivenName) &&
+		containsAny(email, q.Email)
+}
+
+// containsAny はいずれかの文字列に検索ワードが含まれているかを判定する
+func containsAny(values []string, sub string) bool {
+	if sub == "" {
+		return true
+	}
+	for _, v := range values {
+		if strings.Contains(v, sub) {
+			return true
+		}
+	}
+	return false
+}
+
+// PutMulti はNGramでトークナイズされた文字列をSearchプロパティに設定してFooを保存する
+//
+// Stats が設定されている場合は、更新前後のトークンの差分をトークンごとの件数に反映する。
+// 新規に保存したFooの件数も、順位付けに使用するため合わせて記録する。
+func (s *NGramSearcher) PutMulti(ctx context.Context, foos []*Foo) ([]int64, error) {
+	var before [][]string
+	if s.Stats != nil {
+		var ids []int64
+		for _, f := range foos {
+			if f.ID != 0 {
+				ids = append(ids, f.ID)
+			}
+		}
+		var err error
+		if before, err = s.storedTokens(ctx, ids); err != nil {
+			return nil, err
+		}
+	}
+
+	deltas := make(map[string]int)
+	for _, f := range foos {
+		f.Search = s.createNGram(f)
+		tokenStatsDeltas(deltas, nil, f.Search)
+	}
+	ids, err := s.Repository.PutMulti(ctx, foos)
+	if err != nil {
+		return nil, err
+	}
+
+	if s.Stats != nil {
+		for _, tokens := range before {
+			tokenStatsDeltas(deltas, tokens, nil)
+		}
+		if n := len(foos) - len(before); n != 0 {
+			deltas[docCountToken] += n
+		}
+		s.addStats(ctx, deltas)
+	}
+	return ids, nil
+}
+
+// DeleteMulti はFooを削除し、Stats が設定されている場合は削除したFooのトークンの件数を減らす
+func (s *NGramSearcher) DeleteMulti(ctx context.Context, ids []int64) error {
+	if s.Stats == nil {
+		return s.Repository.DeleteMulti(ctx, ids)
+	}
+
+	before, err := s.storedTokens(ctx, ids)
+	if err != nil {
+		return err
+	}
+	if err := s.Repository.DeleteMulti(ctx, ids); err != nil {
+		return err
+	}
+
+	deltas := make(map[string]int)
+	for _, tokens := range before {
+		tokenStatsDeltas(deltas, tokens, nil)
+	}
+	if len(before) != 0 {
+		deltas[docCountToken] -= len(before)
+	}
+	s.addStats(ctx, deltas)
+	return nil
+}
+
+// storedTokens は保存済みのFooのトークンをFooごとに返す
+// 存在しないFooは無視する
+func (s *NGramSearcher) storedTokens(ctx context.Context, ids []int64) ([][]string, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	foos, err := s.Repository.GetMulti(ctx, ids)
+	if _, err := orphanIDs(ids, err); err != nil {
+		return nil, err
+	}
+
+	var tokens [][]string
+	for _, f := range foos {
+		if f != nil {
+			tokens = append(tokens, s.createNGram(f))
+		}
+	}
+	return tokens, nil
+}
+
+// addStats はトークンごとの件数を更新する
+// 件数は検索条件の選択と順位付けにのみ使用するため、更新に失敗しても保存・削除は失敗させない
+// トランザクション内で呼ばれた場合は、多数のトークンのエンティティを更新できないため、コミット後に更新する
+func (s *NGramSearcher) addStats(ctx context.Context, deltas map[string]int) {
+	if len(deltas) == 0 {
+		return
+	}
+	afterCommit(ctx, func(ctx context.Context) {
+		if err := s.Stats.Add(ctx, deltas); err != nil {
+			log.Errorf(ctx, "failed to update token stats; error: %#v", err)
+		}
+	})
+}
+
+func nGram(str string, n int, prefix ...string) []string {
+	if str == "" {
+		return []string{}
+	}
+
+	var (
+		newstr  = str
+		size    = 0
+		runeidx = make([]int, 1, len(str))
+	)
+
+	for len(newstr) > 0 {
+		_, wide := utf8.DecodeRuneInString(newstr)
+		size += wide
+		runeidx = append(runeidx, size)
+		newstr = newstr[wide:]
+	}
+
+	ret := make([]string, 0, len(str)*(len(prefix)+1))
+	for i, j := 0, n; j < len(runeidx); j++ {
+		left, right := runeidx[i], runeidx[j]
+		s := str[left:right]
+		// プレフィックスの指定がない場合はNGramをそのまま返す
+		if len(prefix) == 0 {
+			ret = append(ret, s)
+		}
+		for _, p := range prefix {
+			ret = append(ret, fmt.Sprintf("%s %s", p, s))
+		}
+		i = j - (n - 1)
+	}
+
+	return ret
+}
@@ -0,0 +1,95 @@
+package foosearch
+
+import (
+	"bytes"
+	"unicode"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/unicode/norm"
+)
+
+// Normalizer は検索インデックスの作成前と検索前に文字列を正規化する
+//
+// 全角英数字や半角カナなどはNFKCで統一する。
+// インデックスと検索ワードに同じ正規化を行うことで、表記の揺れがあっても一致するようにする。
+type Normalizer struct {
+	// Fold は大文字と小文字を区別しないように変換するかどうか
+	Fold bool
+	// Kana はカタカナをひらがなに統一するかどうか
+	Kana bool
+	// LongVowel は仮名に続く長音記号を取り除くかどうか
+	// 取り除かない場合も、仮名に続くハイフンや波ダッシュは長音記号に統一する
+	LongVowel bool
+}
+
+// NewNormalizer はNFKCと大文字小文字の変換を行う Normalizer を返す
+func NewNormalizer() *Normalizer {
+	return &Normalizer{Fold: true}
+}
+
+// Normalize は文字列を正規化する。nil の場合は文字列をそのまま返す
+func (n *Normalizer) Normalize(s string) string {
+	if n == nil || s == "" {
+		return s
+	}
+
+	s = norm.NFKC.String(s)
+	if n.Fold {
+		s = cases.Fold().String(s)
+	}
+
+	var (
+		buf  bytes.Buffer
+		prev rune
+	)
+	for _, r := range s {
+		if isLongVowelMark(r) && (isKana(prev) || prev == 'ー') {
+			if n.LongVowel {
+				continue
+			}
+			r = 'ー'
+		}
+		if n.Kana && r >= 'ァ' && r <= 'ヶ' {
+			r -= 'ァ' - 'ぁ'
+		}
+		buf.WriteRune(r)
+		prev = r
+	}
+	return buf.String()
+}
+
+// normalizeQuery は検索ワードを正規化する
+func (n *Normalizer) normalizeQuery(q Query) Query {
+	q.Text = n.Normalize(q.Text)
+	q.FamilyName = n.Normalize(q.FamilyName)
+	q.GivenName = n.Normalize(q.GivenName)
+	q.Email = n.Normalize(q.Email)
+	return q
+}
+
+// normalizeFoo は各項目を正規化したFooのコピーを返す
+func (n *Normalizer) normalizeFoo(f *Foo) *Foo {
+	if n == nil {
+		return f
+	}
+	c := *f
+	c.FamilyName = n.Normalize(f.FamilyName)
+	c.GivenName = n.Normalize(f.GivenName)
+	c.Email = n.Normalize(f.Email)
+	c.FamilyNameKana = n.Normalize(f.FamilyNameKana)
+	c.GivenNameKana = n.Normalize(f.GivenNameKana)
+	return &c
+}
+
+func isKana(r rune) bool {
+	return unicode.In(r, unicode.Hiragana, unicode.Katakana)
+}
+
+// isLongVowelMark は仮名に続く場合に長音として扱う文字かどうかを判定する
+func isLongVowelMark(r rune) bool {
+	switch r {
+	case 'ー', '-', '‐', '‑', '‒', '–', '—', '―', '~', '〜':
+		return true
+	}
+	return false
+}
@@ -0,0 +1,56 @@
+package foosearch
+
+import (
+	"reflect"
+	"testing"
+
+	"golang.org/x/net/context"
+)
+
+func TestNormalizerNormalize(t *testing.T) {
+	tests := []struct {
+		normalizer *Normalizer
+		in         string
+		want       string
+	}{
+		{nil, "ＡＢＣ", "ＡＢＣ"},
+		{NewNormalizer(), "ＡＢＣ", "abc"},
+		{NewNormalizer(), "Straße", "strasse"},
+		{&Normalizer{Kana: true}, "ＡＢＣ", "ABC"},
+		{&Normalizer{Fold: true, Kana: true}, "タナカ", "たなか"},
+		{&Normalizer{Fold: true, Kana: true}, "ﾀﾅｶ", "たなか"},
+		{&Normalizer{Fold: true, Kana: true}, "ラーメン", "らーめん"},
+		{&Normalizer{Fold: true, Kana: true}, "ラ-メン", "らーめん"},
+		{&Normalizer{Kana: true, LongVowel: true}, "ラーメン", "らめん"},
+		{&Normalizer{Kana: true, LongVowel: true}, "ラ-メン", "らめん"},
+	}
+	for _, tt := range tests {
+		if got := tt.normalizer.Normalize(tt.in); got != tt.want {
+			t.Errorf("%+v.Normalize(%q) = %q, want %q", tt.normalizer, tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestNGramSearcherNormalizer(t *testing.T) {
+	s := NewNGramSearcher(NewMemoryRepository())
+	s.Normalizer = &Normalizer{Fold: true, Kana: true}
+	putFoos(t, s)
+
+	tests := []struct {
+		query Query
+		want  []string
+	}{
+		{Query{Text: "ＴＡＮＡＫＡ"}, []string{"田中太郎"}},
+		{Query{FamilyName: "めろん"}, []string{"メロン太郎"}},
+		{Query{FamilyName: "ﾒﾛﾝ"}, []string{"メロン太郎"}},
+	}
+	for _, tt := range tests {
+		resp, err := s.Search(context.Background(), tt.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := fullNames(resp.Items); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Search(%+v) = %v, want %v", tt.query, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,327 @@
+package foosearch
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"golang.org/x/net/context"
+	"google.golang.org/appengine/datastore"
+)
+
+// OrSearcher は項目ごとのクエリを並列に実行することでOR検索を行う
+//
+// 各項目のクエリはキーのみを取得し、結果はいずれもキーの順で返されるため、それらをキーの順にマージすることで
+// 複数のクエリにまたがった結果をページングできるようにしている。
+// 複数の項目に一致したFooはマージの際に1件にまとめ、実データはまとめたキーで一度に取得する。
+//
+// 並び順が指定された場合は、各項目のクエリに同じ並び順を指定して、その順にマージする。
+// この場合は並び替えに値が必要となるため、キーのみではなく実データを取得する。
+type OrSearcher struct {
+	Repository
+}
+
+// NewOrSearcher は指定したリポジトリを検索対象とする OrSearcher を返す
+func NewOrSearcher(repo Repository) *OrSearcher {
+	return &OrSearcher{Repository: repo}
+}
+
+// orSubQuery はOR検索を構成する項目ごとのクエリとその読み込み位置
+type orSubQuery struct {
+	property string
+	query    *EntityQuery
+	t        Iterator
+
+	// match はクエリの結果をメモリ上で絞り込む条件。nil の場合は絞り込まない
+	match func(*Foo) bool
+	// maxScan は match で絞り込む際に、1回の検索で読み込む件数の上限
+	maxScan  int
+	scanned  int
+	rejected int
+	// exhausted は読み込み件数の上限に達したため、続きを読み込まずに中断したかどうか
+	exhausted bool
+
+	// head はまだ結果として返していない先頭のFoo
+	head       *Foo
+	headCursor string
+	// cursor は最後に結果として返したFoo(または除外したFoo)の次の位置
+	cursor string
+	done   bool
+}
+
+// advance はクエリの次の結果を先頭として読み込む
+//
+// match を満たさない結果は読み飛ばし、カーソルをその次の位置に進める。
+func (sq *orSubQuery) advance() error {
+	for {
+		f, err := sq.t.Next()
+		if err == datastore.Done {
+			sq.head, sq.done = nil, true
+			return nil
+		} else if err != nil {
+			return err
+		}
+		c, err := sq.t.Cursor()
+		if err != nil {
+			return err
+		}
+		if sq.match == nil || sq.match(f) {
+			sq.head, sq.headCursor = f, c
+			return nil
+		}
+
+		// 先頭は結果として取り出し済みのため、除外したFooまでは読み込み済みとして扱える
+		sq.head, sq.cursor = nil, c
+		sq.rejected++
+		if sq.scanned++; sq.scanned >= sq.maxScan {
+			sq.exhausted = true
+			return nil
+		}
+	}
+}
+
+// pop は先頭のFooを結果として取り出し、次の結果を読み込む
+func (sq *orSubQuery) pop() (*Foo, error) {
+	f := sq.head
+	sq.cursor = sq.headCursor
+	return f, sq.advance()
+}
+
+// Search は指定された項目のいずれかに完全一致するFooを、並び順の指定がなければキーの順に検索する
+func (s *OrSearcher) Search(ctx context.Context, q Query) (*Response, error) {
+	// 各項目の完全一致を組み合わせるため、あいまい検索はできない
+	if q.Fuzzy != 0 {
+		return nil, &InvalidQueryError{Reason: "fuzzy is not supported by or search"}
+	}
+
+	eq := withOrders(NewEntityQuery(), q.Sort)
+	if len(q.Sort) == 0 {
+		eq = eq.KeysOnly()
+	}
+	var (
+		subs []*orSubQuery
+		// facetQueries は集計に使用する、並び順を指定しないクエリ
+		facetQueries []*EntityQuery
+	)
+	for _, p := range []struct{ property, value string }{
+		{"FamilyName", q.FamilyName},
+		{"GivenName", q.GivenName},
+		{"Email", q.Email},
+	} {
+		if p.value != "" {
+			subs = append(subs, &orSubQuery{property: p.property, query: eq.Filter(p.property+"=", p.value)})
+			facetQueries = append(facetQueries, NewEntityQuery().Filter(p.property+"=", p.value))
+		}
+	}
+	if err := decodeOrCursor(q.Cursor, subs); err != nil {
+		return nil, err
+	}
+
+	// 1ページ分の結果を返すのに各クエリから読み込む必要があるのは、最大でも取得件数より1件多い件数まで
+	limit := pageLimit(q.Limit)
+	foos, matched, err := runSubQueries(ctx, s.Repository, subs, q.Sort, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp *Response
+	if len(q.Sort) == 0 {
+		resp, err = s.getMatched(ctx, foos, matched)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		resp = newResponse(foos)
+		for i := range resp.Items {
+			resp.Items[i].MatchedFields = matched[resp.Items[i].ID]
+		}
+	}
+	if err := setNextCursor(resp, subs, q.Sort); err != nil {
+		return nil, err
+	}
+	if resp.Facets, err = scanFacets(ctx, s.Repository, facetQueries, nil, q.Facets); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// runSubQueries は各クエリを並列に実行し、結果を並び順にマージして最大limit件のFooを返す
+//
+// 複数のクエリに含まれるFooは1件にまとめ、一致したクエリの property を記録する。
+// いずれかのクエリが読み込み件数の上限に達した場合は、その時点までの結果を返す。
+func runSubQueries(ctx context.Context, repo Repository, subs []*orSubQuery, orders []SortOrder, limit int) ([]*Foo, map[int64][]string, error) {
+	var (
+		wg   = new(sync.WaitGroup)
+		mux  = new(sync.Mutex)
+		errs []error
+	)
+	// 検索パラメータが指定されていた場合は検索ワードをフィルタリング条件として並列で検索を行う
+	for _, sq := range subs {
+		if sq.done {
+			continue
+		}
+		wg.Add(1)
+		go func(sq *orSubQuery) {
+			defer wg.Done()
+			n := limit + 1
+			if sq.match != nil {
+				n += sq.maxScan
+			}
+			sq.t = repo.Run(ctx, sq.query.Limit(n).Start(sq.cursor))
+			if err := sq.advance(); err != nil {
+				mux.Lock()
+				defer mux.Unlock()
+				errs = append(errs, err)
+			}
+		}(sq)
+	}
+	wg.Wait()
+
+	if len(errs) != 0 {
+		return nil, nil, fmt.Errorf("%v", errs)
+	}
+
+	// 各クエリの先頭のうち、並び順が最も先のものから順に取り出していく
+	// 同じキーが複数のクエリの先頭にある場合は、まとめて取り出して一致した項目を記録する
+	var (
+		foos    = make([]*Foo, 0, limit)
+		matched = make(map[int64][]string, limit)
+	)
+	for len(foos) < limit && !anyExhausted(subs) {
+		next := minHead(subs, orders)
+		if next == nil {
+			break
+		}
+		head := next.head
+		for _, sq := range subs {
+			if sq.head == nil || sq.head.ID != head.ID {
+				continue
+			}
+			if _, err := sq.pop(); err != nil {
+				return nil, nil, err
+			}
+			matched[head.ID] = append(matched[head.ID], sq.property)
+		}
+		foos = append(foos, head)
+	}
+	return foos, matched, nil
+}
+
+// setNextCursor は続きの結果がある場合に、各クエリの読み込み位置をまとめたカーソルを設定する
+// あわせて、各クエリでメモリ上の絞り込みにより除外した件数を設定する
+func setNextCursor(resp *Response, subs []*orSubQuery, orders []SortOrder) error {
+	for _, sq := range subs {
+		resp.Rejected += sq.rejected
+	}
+	if minHead(subs, orders) == nil && !anyExhausted(subs) {
+		return nil
+	}
+	cursor, err := encodeOrCursor(subs)
+	if err != nil {
+		return err
+	}
+	resp.NextCursor = cursor
+	resp.HasMore = true
+	return nil
+}
+
+// anyExhausted は読み込み件数の上限に達したクエリがあるかを判定する
+func anyExhausted(subs []*orSubQuery) bool {
+	for _, sq := range subs {
+		if sq.exhausted {
+			return true
+		}
+	}
+	return false
+}
+
+// getMatched はキーをもとに実データを取得し、一致した項目を設定した検索結果を返す
+//
+// クエリの実行後に削除されたFooは検索結果から除外する。
+func (s *OrSearcher) getMatched(ctx context.Context, keys []*Foo, matched map[int64][]string) (*Response, error) {
+	ids := make([]int64, len(keys))
+	for i, k := range keys {
+		ids[i] = k.ID
+	}
+	foos, err := s.Repository.GetMulti(ctx, ids)
+	orphans, err := orphanIDs(ids, err)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &Response{Items: make([]Result, 0, len(foos)), Dropped: len(orphans)}
+	for _, f := range foos {
+		if f == nil {
+			continue
+		}
+		resp.Items = append(resp.Items, Result{Foo: f, MatchedFields: matched[f.ID]})
+	}
+	return resp, nil
+}
+
+// minHead は先頭のFooが並び順で最も先にあるクエリを返す
+func minHead(subs []*orSubQuery, orders []SortOrder) *orSubQuery {
+	var min *orSubQuery
+	for _, sq := range subs {
+		if sq.head == nil {
+			continue
+		}
+		if min == nil || compareFoos(sq.head, min.head, orders) < 0 {
+			min = sq
+		}
+	}
+	return min
+}
+
+// orCursorDone は結果をすべて返し終えたクエリのカーソルの値
+const orCursorDone = "done"
+
+// encodeOrCursor は各クエリの読み込み位置をまとめて1つのカーソルにする
+func encodeOrCursor(subs []*orSubQuery) (string, error) {
+	positions := make(map[string]string, len(subs))
+	for _, sq := range subs {
+		if sq.head == nil && !sq.exhausted {
+			positions[sq.property] = orCursorDone
+		} else {
+			positions[sq.property] = sq.cursor
+		}
+	}
+	b, err := json.Marshal(positions)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// decodeOrCursor はカーソルから各クエリの読み込み位置を復元する
+func decodeOrCursor(cursor string, subs []*orSubQuery) error {
+	if cursor == "" {
+		return nil
+	}
+
+	var positions map[string]string
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return &InvalidQueryError{Reason: "invalid cursor"}
+	}
+	if err := json.Unmarshal(b, &positions); err != nil {
+		return &InvalidQueryError{Reason: "invalid cursor"}
+	}
+	// 検索条件を変えて続きを取得しようとした場合は、カーソルと各クエリが対応しなくなる
+	if len(positions) != len(subs) {
+		return &InvalidQueryError{Reason: "cursor does not match the query"}
+	}
+	for _, sq := range subs {
+		pos, ok := positions[sq.property]
+		if !ok {
+			return &InvalidQueryError{Reason: "cursor does not match the query"}
+		}
+		if pos == orCursorDone {
+			sq.done = true
+			continue
+		}
+		sq.cursor = pos
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package foosearch
+
+import (
+	"reflect"
+	"testing"
+
+	"golang.org/x/net/context"
+)
+
+func TestOrSearcherMatchedFields(t *testing.T) {
+	s := NewOrSearcher(NewMemoryRepository())
+	putFoos(t, s)
+
+	resp, err := s.Search(context.Background(), Query{FamilyName: "山田", GivenName: "太郎"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string][]string{
+		"田中太郎":   {"GivenName"},
+		"山田花子":   {"FamilyName"},
+		"山田太郎":   {"FamilyName", "GivenName"},
+		"メロン太郎":  {"GivenName"},
+		"ロンメロ太郎": {"GivenName"},
+	}
+	got := make(map[string][]string)
+	for _, r := range resp.Items {
+		got[r.FamilyName+r.GivenName] = r.MatchedFields
+	}
+	if len(resp.Items) != len(want) || !reflect.DeepEqual(got, want) {
+		t.Errorf("Search() = %v, want MatchedFields %v", resp.Items, want)
+	}
+}
+
+func TestOrSearcherCursor(t *testing.T) {
+	tests := []struct {
+		name   string
+		query  Query
+		cursor func(next string) string
+	}{
+		{"broken cursor", Query{FamilyName: "鈴木", GivenName: "太郎"}, func(string) string { return "!" }},
+		{"other conditions", Query{FamilyName: "鈴木"}, func(next string) string { return next }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewOrSearcher(NewMemoryRepository())
+			putFoos(t, s)
+
+			resp, err := s.Search(context.Background(), Query{FamilyName: "鈴木", GivenName: "太郎", Limit: 1})
+			if err != nil {
+				t.Fatal(err)
+			}
+			q := tt.query
+			q.Cursor = tt.cursor(resp.NextCursor)
+			_, err = s.Search(context.Background(), q)
+			if _, ok := err.(*InvalidQueryError); !ok {
+				t.Errorf("Search(%+v) error = %v, want *InvalidQueryError", q, err)
+			}
+		})
+	}
+}
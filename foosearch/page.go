@@ -0,0 +1,152 @@
+package foosearch
+
+import (
+	"encoding/base64"
+	"strconv"
+
+	"golang.org/x/net/context"
+	"google.golang.org/appengine/datastore"
+)
+
+// 1回の検索で取得する件数
+const (
+	// DefaultLimit は件数が指定されなかった場合の取得件数
+	DefaultLimit = 20
+	// MaxLimit は指定可能な取得件数の上限
+	MaxLimit = 100
+)
+
+// pageLimit は検索条件の取得件数を、デフォルト値と上限を考慮した値に変換する
+func pageLimit(limit int) int {
+	if limit <= 0 {
+		return DefaultLimit
+	}
+	if limit > MaxLimit {
+		return MaxLimit
+	}
+	return limit
+}
+
+// getPage はクエリの結果をカーソルの位置から最大limit件取得する
+//
+// 続きの有無を判定するために1件多く取得し、続きがある場合は次のページのカーソルを設定する。
+func getPage(ctx context.Context, repo Repository, q *EntityQuery, limit int, cursor string) (*Response, error) {
+	limit = pageLimit(limit)
+	t := repo.Run(ctx, q.Limit(limit+1).Start(cursor))
+
+	foos := make([]*Foo, 0, limit)
+	for len(foos) < limit {
+		f, err := t.Next()
+		if err == datastore.Done {
+			return newResponse(foos), nil
+		} else if err != nil {
+			return nil, err
+		}
+		foos = append(foos, f)
+	}
+
+	next, err := t.Cursor()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := t.Next(); err == datastore.Done {
+		return newResponse(foos), nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	resp := newResponse(foos)
+	resp.NextCursor = next
+	resp.HasMore = true
+	return resp, nil
+}
+
+// DefaultMaxScan はメモリ上で絞り込む際に、1回の検索で読み込むエンティティの件数の上限
+const DefaultMaxScan = 1000
+
+func maxScan(n int) int {
+	if n <= 0 {
+		return DefaultMaxScan
+	}
+	return n
+}
+
+// scanPage はクエリの結果を読み込み、match を満たすFooのみをカーソルの位置から最大limit件取得する
+//
+// 読み込む件数は maxScan 件までとし、上限に達した場合は1ページ分に満たなくても
+// 読み込んだ位置までのカーソルを返す。
+// 続きの有無は未読み込みのエンティティが残っているかで判定するため、残りがすべて match を満たさない場合は、
+// HasMore がtrueでも次のページが空になることがある。
+func scanPage(ctx context.Context, repo Repository, q *EntityQuery, limit int, cursor string, maxScan int, match func(*Foo) bool) (*Response, error) {
+	var (
+		t    = repo.Run(ctx, q.Start(cursor).Limit(maxScan+1))
+		foos = make([]*Foo, 0, pageLimit(limit))
+		next string
+		// rejected は除外した件数。rejectedSince はそのうち最後に一致したFoo以降に除外した件数
+		rejected, rejectedSince int
+	)
+	for scanned := 0; scanned < maxScan; scanned++ {
+		f, err := t.Next()
+		if err == datastore.Done {
+			resp := newResponse(foos)
+			resp.Rejected = rejected
+			return resp, nil
+		} else if err != nil {
+			return nil, err
+		}
+		if !match(f) {
+			rejected++
+			rejectedSince++
+			continue
+		}
+		if len(foos) == pageLimit(limit) {
+			// 1ページ分より多く一致したため、最後に一致したFooの次から続きを取得する
+			// 最後に一致したFooより後に読み込んだ分は次のページで改めて判定するため、除外した件数には含めない
+			resp := newResponse(foos)
+			resp.NextCursor, resp.HasMore = next, true
+			resp.Rejected = rejected - rejectedSince
+			return resp, nil
+		}
+		foos = append(foos, f)
+		rejectedSince = 0
+		if next, err = t.Cursor(); err != nil {
+			return nil, err
+		}
+	}
+
+	// 読み込み件数の上限に達した場合は、読み込んだ位置から続きを取得する
+	// 続きがあるかは1件多く読み込んで判定する
+	c, err := t.Cursor()
+	if err != nil {
+		return nil, err
+	}
+	resp := newResponse(foos)
+	resp.Rejected = rejected
+	if _, err := t.Next(); err == datastore.Done {
+		return resp, nil
+	} else if err != nil {
+		return nil, err
+	}
+	resp.NextCursor, resp.HasMore = c, true
+	return resp, nil
+}
+
+// encodeOffsetCursor は結果の先頭からの位置をカーソルの文字列に変換する
+func encodeOffsetCursor(offset int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.Itoa(offset)))
+}
+
+func decodeOffsetCursor(cursor string) (int, error) {
+	if cursor == "" {
+		return 0, nil
+	}
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, &InvalidQueryError{Reason: "invalid cursor"}
+	}
+	offset, err := strconv.Atoi(string(b))
+	if err != nil || offset < 0 {
+		return 0, &InvalidQueryError{Reason: "invalid cursor"}
+	}
+	return offset, nil
+}
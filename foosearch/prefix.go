@@ -0,0 +1,99 @@
+package foosearch
+
+import (
+	"bytes"
+	"unicode/utf8"
+
+	"golang.org/x/net/context"
+)
+
+// PrefixTokenSearcher は前方一致となるトークンを検索インデックスとして前方一致検索を行う
+//
+// NGramSearcher と同様に、各項目の前方一致のトークンをSearchプロパティに保存しておき、
+// 検索時は等価フィルタを指定する。比較フィルタを使用しないため、複数の項目の前方一致をAND条件で検索できる。
+type PrefixTokenSearcher struct {
+	Repository
+}
+
+// NewPrefixTokenSearcher は指定したリポジトリを検索対象とする PrefixTokenSearcher を返す
+func NewPrefixTokenSearcher(repo Repository) *PrefixTokenSearcher {
+	return &PrefixTokenSearcher{Repository: repo}
+}
+
+func createPrefixTokens(f *Foo) []string {
+	var (
+		family = prefixes(f.FamilyName)
+		given  = prefixes(f.GivenName)
+		email  = prefixes(f.Email)
+	)
+
+	index := make([]string, 0, len(family)+len(given)+len(email))
+	index = appendWithPrefix(index, "f", family)
+	index = appendWithPrefix(index, "g", given)
+	index = appendWithPrefix(index, "e", email)
+
+	return index
+}
+
+// Search は指定された項目の前方一致でAND検索を行う
+func (s *PrefixTokenSearcher) Search(ctx context.Context, q Query) (*Response, error) {
+	// トークンの完全一致で検索するため、あいまい検索はできない
+	if q.Fuzzy != 0 {
+		return nil, &InvalidQueryError{Reason: "fuzzy is not supported by prefix token search"}
+	}
+
+	eq := NewEntityQuery()
+	if q.FamilyName != "" {
+		eq = eq.Filter("Search=", "f "+q.FamilyName)
+	}
+	if q.GivenName != "" {
+		eq = eq.Filter("Search=", "g "+q.GivenName)
+	}
+	if q.Email != "" {
+		eq = eq.Filter("Search=", "e "+q.Email)
+	}
+
+	// Searchプロパティと異なるプロパティで並び替える場合は複合インデックスが必要となる
+	resp, err := getPage(ctx, s.Repository, withOrders(eq, q.Sort), q.Limit, q.Cursor)
+	if err != nil {
+		return nil, err
+	}
+	if resp.Facets, err = scanFacets(ctx, s.Repository, []*EntityQuery{eq}, nil, q.Facets); err != nil {
+		return nil, err
+	}
+	highlightPrefixes(resp, prefixConditions(q))
+	return resp, nil
+}
+
+// PutMulti は前方一致のトークンをSearchプロパティに設定してFooを保存する
+func (s *PrefixTokenSearcher) PutMulti(ctx context.Context, foos []*Foo) ([]int64, error) {
+	for _, f := range foos {
+		f.Search = createPrefixTokens(f)
+	}
+	return s.Repository.PutMulti(ctx, foos)
+}
+
+// prefixes は文字列の前方一致となるトークンを列挙する
+func prefixes(s string) []string {
+	var (
+		buf    bytes.Buffer
+		tokens = make([]string, 0, len(s))
+		newS   = s
+	)
+
+	for len(newS) > 0 {
+		char, width := utf8.DecodeRuneInString(newS)
+		buf.WriteRune(char)
+		tokens = append(tokens, buf.String())
+		newS = newS[width:]
+	}
+
+	return tokens
+}
+
+func appendWithPrefix(index []string, prefix string, tokens []string) []string {
+	for _, t := range tokens {
+		index = append(index, prefix+" "+t)
+	}
+	return index
+}
@@ -0,0 +1,311 @@
+package foosearch
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// 検索クエリ言語
+//
+//	query  = or
+//	or     = and { "OR" and }
+//	and    = unary { [ "AND" ] unary }
+//	unary  = "NOT" unary | primary
+//	primary = "(" or ")" | term
+//	term   = [ field ":" ] ( word [ "*" ] | phrase )
+//
+// field には familyName、givenName、email と、メールアドレスの local、domain を指定できる。
+// field を省略した場合は全項目が対象となる。AND、OR、NOT は大文字で指定する。
+
+// queryExpr は検索クエリの構文木
+type queryExpr interface {
+	String() string
+}
+
+// termExpr は1つの検索語
+type termExpr struct {
+	// Field は検索対象の項目のパラメータ名。空の場合は全項目を対象とする
+	Field string
+	Value string
+	// Prefix は `word*` の形式で前方一致が指定されたかどうか
+	Prefix bool
+	// Phrase は引用符で囲まれた語かどうか
+	Phrase bool
+}
+
+func (t termExpr) String() string {
+	var s string
+	if t.Phrase {
+		s = strconv.Quote(t.Value)
+	} else {
+		s = t.Value
+	}
+	if t.Prefix {
+		s += "*"
+	}
+	if t.Field != "" {
+		s = t.Field + ":" + s
+	}
+	return s
+}
+
+type andExpr []queryExpr
+
+func (e andExpr) String() string {
+	return joinExprs(e, " AND ")
+}
+
+type orExpr []queryExpr
+
+func (e orExpr) String() string {
+	return joinExprs(e, " OR ")
+}
+
+type notExpr struct {
+	x queryExpr
+}
+
+func (e notExpr) String() string {
+	return "NOT " + e.x.String()
+}
+
+func joinExprs(exprs []queryExpr, sep string) string {
+	s := make([]string, len(exprs))
+	for i, e := range exprs {
+		s[i] = e.String()
+	}
+	return "(" + strings.Join(s, sep) + ")"
+}
+
+// queryFields は検索クエリで指定できる項目
+var queryFields = map[string]bool{
+	"familyName": true,
+	"givenName":  true,
+	"email":      true,
+	"local":      true,
+	"domain":     true,
+}
+
+// 字句の種類
+const (
+	tokenEOF = iota
+	tokenWord
+	tokenPhrase
+	tokenLParen
+	tokenRParen
+	tokenAnd
+	tokenOr
+	tokenNot
+)
+
+type queryToken struct {
+	kind   int
+	field  string
+	value  string
+	prefix bool
+}
+
+// lexQuery は検索クエリを字句に分割する
+func lexQuery(s string) ([]queryToken, error) {
+	var tokens []queryToken
+	for {
+		s = strings.TrimLeftFunc(s, unicode.IsSpace)
+		if s == "" {
+			return append(tokens, queryToken{kind: tokenEOF}), nil
+		}
+
+		switch s[0] {
+		case '(':
+			tokens = append(tokens, queryToken{kind: tokenLParen})
+			s = s[1:]
+			continue
+		case ')':
+			tokens = append(tokens, queryToken{kind: tokenRParen})
+			s = s[1:]
+			continue
+		}
+
+		// 項目の指定
+		var field string
+		if i := strings.IndexByte(s, ':'); i > 0 && !strings.ContainsAny(s[:i], " \t()\"") {
+			field = s[:i]
+			if !queryFields[field] {
+				return nil, &InvalidQueryError{Reason: "unknown query field: " + field}
+			}
+			s = s[i+1:]
+			// 項目の指定は1つの語にのみ適用でき、括弧でまとめた条件には指定できない
+			if strings.HasPrefix(s, "(") {
+				return nil, &InvalidQueryError{Reason: "grouping after a field is not supported: " + field + ":(...)"}
+			}
+		}
+
+		// 引用符で囲まれた語
+		if strings.HasPrefix(s, `"`) {
+			end := strings.IndexByte(s[1:], '"')
+			if end < 0 {
+				return nil, &InvalidQueryError{Reason: "unterminated quoted phrase"}
+			}
+			phrase := s[1 : end+1]
+			if strings.TrimSpace(phrase) == "" {
+				return nil, &InvalidQueryError{Reason: "empty query term"}
+			}
+			tokens = append(tokens, queryToken{kind: tokenPhrase, field: field, value: phrase})
+			s = s[end+2:]
+			continue
+		}
+
+		end := strings.IndexFunc(s, func(r rune) bool {
+			return unicode.IsSpace(r) || r == '(' || r == ')' || r == '"'
+		})
+		if end < 0 {
+			end = len(s)
+		}
+		word := s[:end]
+		s = s[end:]
+
+		if field == "" {
+			switch word {
+			case "AND":
+				tokens = append(tokens, queryToken{kind: tokenAnd})
+				continue
+			case "OR":
+				tokens = append(tokens, queryToken{kind: tokenOr})
+				continue
+			case "NOT":
+				tokens = append(tokens, queryToken{kind: tokenNot})
+				continue
+			}
+		}
+
+		t := queryToken{kind: tokenWord, field: field, value: word}
+		if strings.HasSuffix(word, "*") {
+			t.value, t.prefix = strings.TrimSuffix(word, "*"), true
+		}
+		if t.value == "" {
+			return nil, &InvalidQueryError{Reason: "empty query term"}
+		}
+		if !utf8.ValidString(t.value) {
+			return nil, &InvalidQueryError{Reason: "invalid query term"}
+		}
+		tokens = append(tokens, t)
+	}
+}
+
+// queryParser は字句の列から構文木を作成する
+type queryParser struct {
+	tokens []queryToken
+	pos    int
+}
+
+// parseQuery は検索クエリを構文木に変換する。空のクエリの場合は nil を返す
+func parseQuery(s string) (queryExpr, error) {
+	tokens, err := lexQuery(s)
+	if err != nil {
+		return nil, err
+	}
+	p := &queryParser{tokens: tokens}
+	if p.peek().kind == tokenEOF {
+		return nil, nil
+	}
+
+	expr, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.peek().kind != tokenEOF {
+		return nil, &InvalidQueryError{Reason: "unexpected token in query"}
+	}
+	return expr, nil
+}
+
+func (p *queryParser) peek() queryToken {
+	return p.tokens[p.pos]
+}
+
+func (p *queryParser) next() queryToken {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *queryParser) parseOr() (queryExpr, error) {
+	x, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	exprs := orExpr{x}
+	for p.peek().kind == tokenOr {
+		p.next()
+		x, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		exprs = append(exprs, x)
+	}
+	if len(exprs) == 1 {
+		return exprs[0], nil
+	}
+	return exprs, nil
+}
+
+func (p *queryParser) parseAnd() (queryExpr, error) {
+	x, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	exprs := andExpr{x}
+	for {
+		switch p.peek().kind {
+		case tokenAnd:
+			p.next()
+		case tokenWord, tokenPhrase, tokenLParen, tokenNot:
+			// AND を省略した場合も AND 条件とする
+		default:
+			if len(exprs) == 1 {
+				return exprs[0], nil
+			}
+			return exprs, nil
+		}
+		x, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		exprs = append(exprs, x)
+	}
+}
+
+func (p *queryParser) parseUnary() (queryExpr, error) {
+	if p.peek().kind == tokenNot {
+		p.next()
+		x, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return notExpr{x: x}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *queryParser) parsePrimary() (queryExpr, error) {
+	t := p.next()
+	switch t.kind {
+	case tokenLParen:
+		x, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.next().kind != tokenRParen {
+			return nil, &InvalidQueryError{Reason: "missing closing parenthesis in query"}
+		}
+		return x, nil
+	case tokenWord:
+		return termExpr{Field: t.field, Value: t.value, Prefix: t.prefix}, nil
+	case tokenPhrase:
+		return termExpr{Field: t.field, Value: t.value, Phrase: true}, nil
+	}
+	return nil, &InvalidQueryError{Reason: "unexpected token in query"}
+}
@@ -0,0 +1,61 @@
+package foosearch
+
+import "testing"
+
+func TestParseQuery(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"", ""},
+		{"鈴木", "鈴木"},
+		{"a*", "a*"},
+		{"a b", "(a AND b)"},
+		{"a OR b c", "(a OR (b AND c))"},
+		{"a b OR c", "((a AND b) OR c)"},
+		{"(a OR b) c", "((a OR b) AND c)"},
+		{"NOT a b", "(NOT a AND b)"},
+		{"a AND NOT (b OR c)", "(a AND NOT (b OR c))"},
+		{`familyName:鈴* NOT "t y"`, `(familyName:鈴* AND NOT "t y")`},
+		{"local:x domain:y.com", "(local:x AND domain:y.com)"},
+	}
+	for _, tt := range tests {
+		expr, err := parseQuery(tt.query)
+		if err != nil {
+			t.Errorf("parseQuery(%q) error = %v", tt.query, err)
+			continue
+		}
+		got := ""
+		if expr != nil {
+			got = expr.String()
+		}
+		if got != tt.want {
+			t.Errorf("parseQuery(%q) = %q, want %q", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestParseQueryError(t *testing.T) {
+	tests := []struct {
+		query  string
+		reason string
+	}{
+		{`""`, "empty query term"},
+		{`"  "`, "empty query term"},
+		{"familyName:(a OR b)", "grouping after a field is not supported: familyName:(...)"},
+		{"(a", "missing closing parenthesis in query"},
+		{"unknown:x", "unknown query field: unknown"},
+		{"a OR", "unexpected token in query"},
+	}
+	for _, tt := range tests {
+		_, err := parseQuery(tt.query)
+		e, ok := err.(*InvalidQueryError)
+		if !ok {
+			t.Errorf("parseQuery(%q) error = %v, want *InvalidQueryError", tt.query, err)
+			continue
+		}
+		if e.Reason != tt.reason {
+			t.Errorf("parseQuery(%q) reason = %q, want %q", tt.query, e.Reason, tt.reason)
+		}
+	}
+}
@@ -0,0 +1,352 @@
+package foosearch
+
+import (
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/context"
+)
+
+// MaxQueryBranches は検索クエリを OR で分割した際のクエリの件数の上限
+const MaxQueryBranches = 8
+
+// queryLiteral は否定の有無を含めた1つの検索語
+type queryLiteral struct {
+	term    termExpr
+	negated bool
+}
+
+func (l queryLiteral) String() string {
+	if l.negated {
+		return "NOT " + l.term.String()
+	}
+	return l.term.String()
+}
+
+// toDNF は構文木を、AND条件の検索語の組(ブランチ)を OR でつないだ形に変換する
+//
+// NOT はド・モルガンの法則で検索語の直前まで移動させる。
+// ブランチの件数が MaxQueryBranches を超える場合はエラーとする。
+func toDNF(expr queryExpr, negated bool) ([][]queryLiteral, error) {
+	switch e := expr.(type) {
+	case termExpr:
+		return [][]queryLiteral{{{term: e, negated: negated}}}, nil
+	case notExpr:
+		return toDNF(e.x, !negated)
+	case andExpr:
+		if negated {
+			return orBranches([]queryExpr(e), true)
+		}
+		return andBranches([]queryExpr(e), false)
+	case orExpr:
+		if negated {
+			return andBranches([]queryExpr(e), true)
+		}
+		return orBranches([]queryExpr(e), false)
+	}
+	return nil, &InvalidQueryError{Reason: "unsupported query"}
+}
+
+func orBranches(exprs []queryExpr, negated bool) ([][]queryLiteral, error) {
+	var branches [][]queryLiteral
+	for _, x := range exprs {
+		b, err := toDNF(x, negated)
+		if err != nil {
+			return nil, err
+		}
+		branches = append(branches, b...)
+		if len(branches) > MaxQueryBranches {
+			return nil, &InvalidQueryError{Reason: "query has too many OR branches"}
+		}
+	}
+	return branches, nil
+}
+
+func andBranches(exprs []queryExpr, negated bool) ([][]queryLiteral, error) {
+	branches := [][]queryLiteral{nil}
+	for _, x := range exprs {
+		b, err := toDNF(x, negated)
+		if err != nil {
+			return nil, err
+		}
+		if len(branches)*len(b) > MaxQueryBranches {
+			return nil, &InvalidQueryError{Reason: "query has too many OR branches"}
+		}
+		product := make([][]queryLiteral, 0, len(branches)*len(b))
+		for _, left := range branches {
+			for _, right := range b {
+				branch := append(append([]queryLiteral(nil), left...), right...)
+				product = append(product, branch)
+			}
+		}
+		branches = product
+	}
+	return branches, nil
+}
+
+// 検索語ごとの検索方式
+const (
+	// PlanEqual は等価フィルタで検索する(simple-datastore と同じ方式)
+	PlanEqual = "equal"
+	// PlanRangePrefix は比較フィルタで前方一致検索する(forward-match-datastore と同じ方式)
+	PlanRangePrefix = "range-prefix"
+	// PlanNGram はSearchプロパティのトークンで検索する(ngram-datastore と同じ方式)
+	PlanNGram = "ngram"
+	// PlanMemory はクエリには指定せず、メモリ上の絞り込みのみで検索する
+	PlanMemory = "memory"
+)
+
+// クエリ全体の検索方式
+const (
+	// PlanSingle は1つのクエリで検索する
+	PlanSingle = "single"
+	// PlanParallelOr はブランチごとのクエリを並列に実行してマージする(or-search-datastore と同じ方式)
+	PlanParallelOr = "parallel-or"
+)
+
+// QueryPlan は検索クエリの実行計画
+type QueryPlan struct {
+	// Query は解析した検索クエリ
+	Query    string       `json:"query"`
+	Strategy string       `json:"strategy"`
+	Branches []BranchPlan `json:"branches"`
+}
+
+// BranchPlan はAND条件の検索語の組(ブランチ)ごとの実行計画
+type BranchPlan struct {
+	Clauses []ClausePlan `json:"clauses"`
+	// Filters はDatastoreのクエリに指定するフィルタ
+	Filters []string `json:"filters"`
+}
+
+// ClausePlan は検索語ごとの実行計画
+//
+// いずれの検索方式でも、クエリの結果はメモリ上ですべての検索語を満たすかを検証する。
+type ClausePlan struct {
+	Clause   string `json:"clause"`
+	Strategy string `json:"strategy"`
+	// Tokens は PlanNGram の場合にクエリに指定したトークン
+	Tokens []string `json:"tokens,omitempty"`
+}
+
+// queryBranch はAND条件の検索語の組を、Datastoreのクエリとメモリ上での絞り込み条件に変換したもの
+type queryBranch struct {
+	literals []queryLiteral
+	query    *EntityQuery
+	plan     BranchPlan
+	pin      branchPin
+}
+
+// branchPin は続きのページで同じクエリを実行するために、カーソルに記録するブランチの実行計画
+//
+// 比較フィルタとする検索語やクエリに指定するトークンは件数をもとに選ぶため、
+// ページごとに選び直すと異なるクエリとなり、カーソルが使用できなくなる。
+type branchPin struct {
+	// Range は比較フィルタとした検索語の位置。使用しない場合は-1
+	Range int `json:"range"`
+	// Tokens はクエリに指定したトークン
+	Tokens []string `json:"tokens,omitempty"`
+}
+
+// planBranch は検索語ごとに検索方式を選び、ブランチをDatastoreのクエリに変換する
+//
+//   - 項目を指定した完全一致の検索語は等価フィルタとする
+//   - Normalizer を指定した場合、項目を指定した検索語は正規化前の値を持つプロパティへのフィルタでは判定できないため、項目のトークンとする
+//   - 項目を指定しない検索語と local、domain の検索語はSearchプロパティのトークンとする
+//   - 前方一致の検索語は、他にフィルタがなければ比較フィルタとする。複数ある場合は最も件数の少ないものを選び、
+//     残りの前方一致の検索語はメモリ上で絞り込む。
+//     比較フィルタは結果がそのプロパティの順になりキーの順にマージできないため、OR を含む場合は使用しない。
+//     また、他のフィルタと組み合わせると複合インデックスが必要となるため、その場合は項目のトークンとする
+//   - NOT の検索語はメモリ上で絞り込む
+//
+// トークンは件数の少ないものから MaxFilters 件までをクエリに指定し、指定しなかったトークンのみの検索語はメモリ上で絞り込む。
+// pin を指定した場合は件数を数えずに、最初のページで選んだ比較フィルタとトークンを使用する。
+func (s *QuerySearcher) planBranch(ctx context.Context, literals []queryLiteral, single bool, pin *branchPin) (*queryBranch, error) {
+	var (
+		eq         = NewEntityQuery()
+		strategies = make([]string, len(literals))
+		tokens     = make([][]string, len(literals))
+		prefixes   []int
+	)
+	all, family, given, email := s.fields()
+	fields := map[string]ngramField{"FamilyName": family, "GivenName": given, "Email": email}
+
+	for i, l := range literals {
+		property, ok := sortParams[l.term.Field]
+		switch {
+		case l.negated:
+			strategies[i] = PlanMemory
+		case ok && s.Normalizer != nil:
+			tokens[i] = fields[property].queryTokens(s.Normalizer.Normalize(l.term.Value))
+		case ok && !l.term.Prefix:
+			eq = eq.Filter(property+"=", l.term.Value)
+			strategies[i] = PlanEqual
+		case ok && l.term.Prefix:
+			prefixes = append(prefixes, i)
+		case l.term.Field == "local" || l.term.Field == "domain":
+			tokens[i] = emailField.queryTokens(s.Normalizer.Normalize(l.term.Field + ":" + l.term.Value))
+		default:
+			tokens[i] = all.queryTokens(s.Normalizer.Normalize(l.term.Value))
+		}
+	}
+
+	// 前方一致の検索語のうち、比較フィルタとするものを選ぶ
+	conds := make([]prefixCondition, len(prefixes))
+	for j, i := range prefixes {
+		conds[j] = prefixCondition{property: sortParams[literals[i].term.Field], prefix: literals[i].term.Value}
+	}
+	rangeIdx := -1
+	canRange := single && len(prefixes) != 0 && len(eq.Filters()) == 0 && !hasTokens(tokens)
+	switch {
+	case pin != nil:
+		if pin.Range != -1 {
+			if !canRange || indexOf(prefixes, pin.Range) < 0 {
+				return nil, &InvalidQueryError{Reason: "cursor does not match the query"}
+			}
+			rangeIdx = pin.Range
+		}
+	case canRange:
+		fm := &ForwardMatchSearcher{Repository: s.Repository, MaxScan: s.MaxScan}
+		j, err := fm.rangeCondition(ctx, conds, nil)
+		if err != nil {
+			return nil, err
+		}
+		rangeIdx = prefixes[j]
+	}
+	for j, i := range prefixes {
+		switch {
+		case i == rangeIdx:
+			eq = conds[j].query(eq)
+			strategies[i] = PlanRangePrefix
+		case rangeIdx != -1:
+			// 比較フィルタと他のフィルタを組み合わせると複合インデックスが必要となるため、メモリ上で絞り込む
+			strategies[i] = PlanMemory
+		default:
+			f := fields[conds[j].property]
+			tokens[i] = f.queryTokens(s.Normalizer.Normalize(literals[i].term.Value))
+		}
+	}
+
+	// 件数の少ないトークンからクエリに指定する
+	var flat []string
+	for _, t := range tokens {
+		flat = append(flat, t...)
+	}
+	flat = uniqueTokens(flat)
+	var queryTokens []string
+	if pin != nil {
+		known := make(map[string]bool, len(flat))
+		for _, t := range flat {
+			known[t] = true
+		}
+		for _, t := range pin.Tokens {
+			if !known[t] {
+				return nil, &InvalidQueryError{Reason: "cursor does not match the query"}
+			}
+		}
+		queryTokens = pin.Tokens
+	} else {
+		queryTokens = s.selectTokens(ctx, flat)
+	}
+	selected := make(map[string]bool)
+	for _, t := range queryTokens {
+		eq = eq.Filter("Search=", t)
+		selected[t] = true
+	}
+
+	b := &queryBranch{literals: literals, query: eq, pin: branchPin{Range: rangeIdx, Tokens: queryTokens}}
+	for i, l := range literals {
+		c := ClausePlan{Clause: l.String(), Strategy: strategies[i]}
+		if c.Strategy == "" {
+			c.Strategy = PlanMemory
+			for _, t := range tokens[i] {
+				if selected[t] {
+					c.Strategy = PlanNGram
+					c.Tokens = append(c.Tokens, t)
+				}
+			}
+		}
+		b.plan.Clauses = append(b.plan.Clauses, c)
+	}
+	for _, f := range eq.Filters() {
+		b.plan.Filters = append(b.plan.Filters, f.Property+" "+f.Op+" "+f.Value)
+	}
+	return b, nil
+}
+
+func indexOf(values []int, v int) int {
+	for i, x := range values {
+		if x == v {
+			return i
+		}
+	}
+	return -1
+}
+
+func hasTokens(tokens [][]string) bool {
+	for _, t := range tokens {
+		if len(t) != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// match はFooがブランチのすべての検索語を満たすかを判定する
+func (s *QuerySearcher) match(f *Foo, literals []queryLiteral) bool {
+	nf := s.Normalizer.normalizeFoo(f)
+	for _, l := range literals {
+		if s.matchTerm(f, nf, l.term) == l.negated {
+			return false
+		}
+	}
+	return true
+}
+
+// matchTerm はFooが検索語に一致するかを判定する。nf は正規化したFoo
+//
+// 項目を指定した検索語は、Normalizer を指定しない場合はクエリのフィルタと同じく項目の値のみと比較し、
+// 指定した場合は項目のトークンと同じく正規化した値と読みと比較する。
+func (s *QuerySearcher) matchTerm(f, nf *Foo, t termExpr) bool {
+	value := s.Normalizer.Normalize(t.Value)
+	family, given, email := searchableValues(nf)
+	if property, ok := sortParams[t.Field]; ok {
+		values := map[string][]string{"FamilyName": family, "GivenName": given, "Email": email}[property]
+		if s.Normalizer == nil {
+			values, _ = propertyValues(f, property)
+		}
+		for _, v := range values {
+			if t.Prefix && strings.HasPrefix(v, value) || !t.Prefix && v == value {
+				return true
+			}
+		}
+		return false
+	}
+
+	if t.Field == "local" || t.Field == "domain" {
+		return hasEmailTerms(nf.Email, []string{s.Normalizer.Normalize(t.Field + ":" + t.Value)})
+	}
+	for _, values := range [][]string{family, given, email} {
+		for _, v := range values {
+			if t.Prefix && strings.HasPrefix(v, value) || !t.Prefix && strings.Contains(v, value) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// subQueries はブランチごとのクエリを、OR検索と同様に並列に実行するためのクエリに変換する
+func (s *QuerySearcher) subQueries(branches []*queryBranch) []*orSubQuery {
+	subs := make([]*orSubQuery, len(branches))
+	for i, b := range branches {
+		literals := b.literals
+		subs[i] = &orSubQuery{
+			property: strconv.Itoa(i),
+			query:    b.query,
+			match:    func(f *Foo) bool { return s.match(f, literals) },
+			maxScan:  maxScan(s.MaxScan),
+		}
+	}
+	return subs
+}
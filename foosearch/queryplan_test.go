@@ -0,0 +1,142 @@
+package foosearch
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/context"
+)
+
+func TestToDNF(t *testing.T) {
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"a b", []string{"a AND b"}},
+		{"a OR b c", []string{"a", "b AND c"}},
+		{"(a OR b) c", []string{"a AND c", "b AND c"}},
+		{"NOT (a OR b)", []string{"NOT a AND NOT b"}},
+		{"NOT (a b)", []string{"NOT a", "NOT b"}},
+	}
+	for _, tt := range tests {
+		expr, err := parseQuery(tt.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		branches, err := toDNF(expr, false)
+		if err != nil {
+			t.Errorf("toDNF(%q) error = %v", tt.query, err)
+			continue
+		}
+		var got []string
+		for _, b := range branches {
+			clauses := make([]string, len(b))
+			for i, l := range b {
+				clauses[i] = l.String()
+			}
+			got = append(got, strings.Join(clauses, " AND "))
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("toDNF(%q) = %q, want %q", tt.query, got, tt.want)
+		}
+	}
+
+	expr, err := parseQuery("(a OR b) (c OR d) (e OR f) (g OR h)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := toDNF(expr, false); err == nil {
+		t.Error("toDNF with 16 branches succeeded, want error")
+	}
+}
+
+func TestQuerySearcherPlan(t *testing.T) {
+	tests := []struct {
+		query      string
+		strategy   string
+		strategies [][]string
+	}{
+		{"familyName:鈴木", PlanSingle, [][]string{{PlanEqual}}},
+		{"familyName:鈴*", PlanSingle, [][]string{{PlanRangePrefix}}},
+		// 件数の少ない名の比較フィルタを使用し、姓はメモリ上で絞り込む
+		{"familyName:鈴* givenName:一*", PlanSingle, [][]string{{PlanMemory, PlanRangePrefix}}},
+		{"太郎 familyName:山*", PlanSingle, [][]string{{PlanNGram, PlanNGram}}},
+		{"NOT 太郎 familyName:鈴木", PlanSingle, [][]string{{PlanMemory, PlanEqual}}},
+		{"familyName:鈴木 OR 太郎", PlanParallelOr, [][]string{{PlanEqual}, {PlanNGram}}},
+	}
+	for _, tt := range tests {
+		s := NewQuerySearcher(NewMemoryRepository())
+		putFoos(t, s)
+
+		resp, err := s.Search(context.Background(), Query{Text: tt.query, Explain: true})
+		if err != nil {
+			t.Errorf("Search(%q) error = %v", tt.query, err)
+			continue
+		}
+		if resp.Plan.Strategy != tt.strategy {
+			t.Errorf("Search(%q) strategy = %q, want %q", tt.query, resp.Plan.Strategy, tt.strategy)
+		}
+		var got [][]string
+		for _, b := range resp.Plan.Branches {
+			var strategies []string
+			for _, c := range b.Clauses {
+				strategies = append(strategies, c.Strategy)
+			}
+			got = append(got, strategies)
+		}
+		if !reflect.DeepEqual(got, tt.strategies) {
+			t.Errorf("Search(%q) clause strategies = %v, want %v", tt.query, got, tt.strategies)
+		}
+	}
+}
+
+func TestQuerySearcherCursorMismatch(t *testing.T) {
+	s := NewQuerySearcher(NewMemoryRepository())
+	putFoos(t, s)
+
+	resp, err := s.Search(context.Background(), Query{Text: "familyName:鈴* OR givenName:太郎", Limit: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.Search(context.Background(), Query{Text: "familyName:鈴*", Cursor: resp.NextCursor})
+	if _, ok := err.(*InvalidQueryError); !ok {
+		t.Errorf("Search with a cursor of another query error = %v, want *InvalidQueryError", err)
+	}
+}
+
+func TestQuerySearcherNormalizer(t *testing.T) {
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		// 読みのカタカナもひらがなに正規化して比較する
+		{"familyName:タナカ*", []string{"田中太郎"}},
+		{"familyName:めろん", []string{"メロン太郎"}},
+		{"email:H-Yamada*", []string{"山田花子"}},
+		{"email:T-YAMADA@SAMPLE.COM", []string{"山田太郎"}},
+		{"NOT familyName:タナカ* givenName:太郎", []string{"山田太郎", "メロン太郎", "ロンメロ太郎"}},
+	}
+	for _, tt := range tests {
+		s := NewQuerySearcher(NewMemoryRepository())
+		s.Normalizer = &Normalizer{Fold: true, Kana: true}
+		putFoos(t, s)
+
+		resp, err := s.Search(context.Background(), Query{Text: tt.query, Explain: true})
+		if err != nil {
+			t.Errorf("Search(%q) error = %v", tt.query, err)
+			continue
+		}
+		if got := fullNames(resp.Items); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Search(%q) = %q, want %q", tt.query, got, tt.want)
+		}
+		// 正規化前の値を持つプロパティへのフィルタは使用しない
+		for _, b := range resp.Plan.Branches {
+			for _, c := range b.Clauses {
+				if c.Strategy == PlanEqual || c.Strategy == PlanRangePrefix {
+					t.Errorf("Search(%q) clause %q strategy = %q", tt.query, c.Clause, c.Strategy)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,224 @@
+package foosearch
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+
+	"golang.org/x/net/context"
+)
+
+// QuerySearcher は `q` パラメータを検索クエリ言語として解釈し、これまでの検索方式を組み合わせて検索を行う
+//
+// 検索クエリは OR でつながれたAND条件の組(ブランチ)に変換し、検索語ごとに等価フィルタ、
+// 比較フィルタによる前方一致、NGramのトークン、メモリ上の絞り込みのいずれかの検索方式を選んでクエリを作成する。
+// ブランチが複数ある場合は OrSearcher と同様に各クエリを並列に実行し、キーの順にマージする。
+// いずれの場合も、クエリの結果はメモリ上で検索クエリのすべての条件を満たすかを検証してから返す。
+// Query.Explain を指定した場合は、選んだ検索方式を実行計画としてレスポンスに含める。
+//
+// NGramのトークンを保存するため NGramSearcher を埋め込んでおり、保存・削除は NGramSearcher と同じ処理となる。
+// Normalizer を指定しない場合は等価フィルタと比較フィルタを使用するため、リポジトリは各項目のインデックスを作成する必要がある。
+type QuerySearcher struct {
+	*NGramSearcher
+}
+
+// NewQuerySearcher は指定したリポジトリを検索対象とする QuerySearcher を返す
+func NewQuerySearcher(repo Repository) *QuerySearcher {
+	return &QuerySearcher{NGramSearcher: NewNGramSearcher(repo)}
+}
+
+// Search は `q` パラメータの検索クエリと、各項目のパラメータの完全一致をAND条件として検索する
+func (s *QuerySearcher) Search(ctx context.Context, q Query) (*Response, error) {
+	if q.Fuzzy != 0 {
+		return nil, &InvalidQueryError{Reason: "fuzzy is not supported by query language search"}
+	}
+	// 複数のクエリをキーの順にマージするため並び替えはできない
+	if len(q.Sort) != 0 {
+		return nil, &InvalidQueryError{Reason: "sort is not supported by query language search"}
+	}
+
+	literals, err := s.branches(q)
+	if err != nil {
+		return nil, err
+	}
+
+	// 続きのページでは、カーソルに記録した最初のページの実行計画を使用する
+	cursor, err := decodeQueryCursor(q.Cursor, len(literals))
+	if err != nil {
+		return nil, err
+	}
+
+	// ブランチが1つの場合は比較フィルタを使用できる
+	single := len(literals) == 1
+	branches := make([]*queryBranch, len(literals))
+	for i, l := range literals {
+		var pin *branchPin
+		if cursor != nil {
+			pin = &cursor.Branches[i]
+		}
+		if branches[i], err = s.planBranch(ctx, l, single, pin); err != nil {
+			return nil, err
+		}
+	}
+
+	subs := s.subQueries(branches)
+	if cursor != nil {
+		if err := decodeOrCursor(cursor.Positions, subs); err != nil {
+			return nil, err
+		}
+	}
+
+	foos, _, err := runSubQueries(ctx, s.Repository, subs, nil, pageLimit(q.Limit))
+	if err != nil {
+		return nil, err
+	}
+	resp := newResponse(foos)
+	if err := setNextCursor(resp, subs, nil); err != nil {
+		return nil, err
+	}
+	if resp.HasMore {
+		if resp.NextCursor, err = encodeQueryCursor(branches, resp.NextCursor); err != nil {
+			return nil, err
+		}
+	}
+	if resp.Facets, err = s.facets(ctx, branches, q.Facets); err != nil {
+		return nil, err
+	}
+	s.highlight(resp, literals)
+	if q.Explain {
+		resp.Plan = explain(literals, branches)
+	}
+	return resp, nil
+}
+
+// highlight は否定していない検索語が出現する範囲を、項目ごとに強調して検索結果に設定する
+//
+// 項目を指定した検索語はその項目で、local と domain の検索語はメールアドレスで、それ以外の検索語はすべての項目で探す。
+// 項目を指定しない検索語は、NGram検索と同様にそのまま含まれない場合はトークンが出現する範囲を強調する。
+func (s *QuerySearcher) highlight(resp *Response, literals [][]queryLiteral) {
+	all, _, _, _ := s.fields()
+	words := make(map[string][]highlightWord)
+	for _, branch := range literals {
+		for _, l := range branch {
+			if l.negated {
+				continue
+			}
+			value := s.Normalizer.Normalize(l.term.Value)
+			if property, ok := sortParams[l.term.Field]; ok {
+				words[property] = append(words[property], highlightWord{word: value})
+				continue
+			}
+			if l.term.Field == "local" || l.term.Field == "domain" {
+				words["Email"] = append(words["Email"], highlightWord{word: value})
+				continue
+			}
+			w := highlightWord{word: value, tokens: all.tokenizer.QueryTokens(value)}
+			for _, field := range highlightFields {
+				words[field] = append(words[field], w)
+			}
+		}
+	}
+	setHighlights(resp, s.Normalizer, words)
+}
+
+// facets は各ブランチのクエリで候補を読み込み、いずれかのブランチを満たすFooを集計する
+func (s *QuerySearcher) facets(ctx context.Context, branches []*queryBranch, names []string) (map[string][]FacetCount, error) {
+	queries := make([]*EntityQuery, len(branches))
+	for i, b := range branches {
+		queries[i] = b.query
+	}
+	return scanFacets(ctx, s.Repository, queries, func(f *Foo) bool {
+		for _, b := range branches {
+			if s.match(f, b.literals) {
+				return true
+			}
+		}
+		return false
+	}, names)
+}
+
+// queryCursor はブランチごとの実行計画と、各ブランチのクエリの読み込み位置
+type queryCursor struct {
+	Branches []branchPin `json:"branches"`
+	// Positions は encodeOrCursor で変換した各クエリの読み込み位置
+	Positions string `json:"positions"`
+}
+
+func encodeQueryCursor(branches []*queryBranch, positions string) (string, error) {
+	c := queryCursor{Positions: positions}
+	for _, b := range branches {
+		c.Branches = append(c.Branches, b.pin)
+	}
+	body, err := json.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(body), nil
+}
+
+// decodeQueryCursor はカーソルから実行計画と読み込み位置を復元する。カーソルが空の場合はnilを返す
+func decodeQueryCursor(cursor string, branches int) (*queryCursor, error) {
+	if cursor == "" {
+		return nil, nil
+	}
+	var c queryCursor
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil || json.Unmarshal(b, &c) != nil {
+		return nil, &InvalidQueryError{Reason: "invalid cursor"}
+	}
+	if len(c.Branches) != branches {
+		return nil, &InvalidQueryError{Reason: "cursor does not match the query"}
+	}
+	return &c, nil
+}
+
+// explain はブランチごとの実行計画をまとめる
+func explain(literals [][]queryLiteral, branches []*queryBranch) *QueryPlan {
+	plan := &QueryPlan{Strategy: PlanSingle}
+	if len(branches) > 1 {
+		plan.Strategy = PlanParallelOr
+	}
+
+	exprs := make([]string, len(literals))
+	for i, branch := range literals {
+		clauses := make([]string, len(branch))
+		for j, l := range branch {
+			clauses[j] = l.String()
+		}
+		exprs[i] = "(" + strings.Join(clauses, " AND ") + ")"
+		plan.Branches = append(plan.Branches, branches[i].plan)
+	}
+	plan.Query = strings.Join(exprs, " OR ")
+	return plan
+}
+
+// branches は検索クエリと各項目のパラメータを、ブランチごとの検索語の組に変換する
+func (s *QuerySearcher) branches(q Query) ([][]queryLiteral, error) {
+	expr, err := parseQuery(q.Text)
+	if err != nil {
+		return nil, err
+	}
+
+	branches := [][]queryLiteral{nil}
+	if expr != nil {
+		if branches, err = toDNF(expr, false); err != nil {
+			return nil, err
+		}
+	}
+
+	// 各項目のパラメータは完全一致の条件として、すべてのブランチに加える
+	var params []queryLiteral
+	for _, p := range []struct{ field, value string }{
+		{"familyName", q.FamilyName},
+		{"givenName", q.GivenName},
+		{"email", q.Email},
+	} {
+		if p.value != "" {
+			params = append(params, queryLiteral{term: termExpr{Field: p.field, Value: p.value}})
+		}
+	}
+	for i := range branches {
+		branches[i] = append(branches[i], params...)
+	}
+	return branches, nil
+}
@@ -0,0 +1,187 @@
+package foosearch
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/net/context"
+	"google.golang.org/appengine"
+	"google.golang.org/appengine/datastore"
+)
+
+// Repository はFooの永続化を抽象化したもの
+//
+// エラーはDatastoreのパッケージと同じ規約に従い、存在しないエンティティに対しては
+// datastore.ErrNoSuchEntity を、GetMultiでは appengine.MultiError を返す。
+type Repository interface {
+	Get(ctx context.Context, id int64) (*Foo, error)
+	GetMulti(ctx context.Context, ids []int64) ([]*Foo, error)
+	// PutMulti はIDが0のFooは新規に採番して、それ以外は指定のIDで保存する
+	PutMulti(ctx context.Context, foos []*Foo) ([]int64, error)
+	DeleteMulti(ctx context.Context, ids []int64) error
+	GetAll(ctx context.Context, q *EntityQuery) ([]*Foo, error)
+	Run(ctx context.Context, q *EntityQuery) Iterator
+}
+
+// Iterator はクエリの結果を1件ずつ取得する
+type Iterator interface {
+	// Next は次のFooを返す。結果がなくなった場合は datastore.Done を返す
+	Next() (*Foo, error)
+	// Cursor は直前に返したFooの次の位置を表すカーソルを返す
+	Cursor() (string, error)
+}
+
+// Filter はエンティティの絞り込み条件
+type Filter struct {
+	Property string
+	Op       string
+	Value    string
+}
+
+// EntityQuery はリポジトリに対する検索条件
+type EntityQuery struct {
+	filters  []Filter
+	orders   []string
+	limit    int
+	start    string
+	keysOnly bool
+	err      error
+}
+
+// NewEntityQuery は条件を持たない EntityQuery を返す
+func NewEntityQuery() *EntityQuery {
+	return &EntityQuery{}
+}
+
+// Filter は datastore.Query.Filter と同じ形式の条件を追加した EntityQuery を返す
+//
+// filterStr は "FamilyName=" や "FamilyName >=" のようにプロパティ名と演算子をつなげたもの。
+func (q *EntityQuery) Filter(filterStr string, value string) *EntityQuery {
+	newQ := *q
+	// datastore.Query と同様に、不正な条件はクエリの実行時にエラーとして返す
+	f, err := parseFilter(filterStr, value)
+	if err != nil {
+		newQ.err = err
+		return &newQ
+	}
+	newQ.filters = append(append([]Filter(nil), q.filters...), f)
+	return &newQ
+}
+
+// Filters は追加された条件の一覧を返す
+func (q *EntityQuery) Filters() []Filter {
+	return q.filters
+}
+
+// Order は datastore.Query.Order と同じ形式で並び順を追加した EntityQuery を返す
+//
+// fieldName の先頭に `-` をつけた場合は降順となる。`__key__` を指定した場合はキーの順となる。
+func (q *EntityQuery) Order(fieldName string) *EntityQuery {
+	newQ := *q
+	newQ.orders = append(append([]string(nil), q.orders...), fieldName)
+	return &newQ
+}
+
+// Orders は追加された並び順の一覧を返す
+func (q *EntityQuery) Orders() []string {
+	return q.orders
+}
+
+// Limit は取得する件数の上限を設定した EntityQuery を返す。0以下の場合は上限を設けない
+func (q *EntityQuery) Limit(limit int) *EntityQuery {
+	newQ := *q
+	newQ.limit = limit
+	return &newQ
+}
+
+// Start は指定したカーソルの位置から結果を取得する EntityQuery を返す
+func (q *EntityQuery) Start(cursor string) *EntityQuery {
+	newQ := *q
+	newQ.start = cursor
+	return &newQ
+}
+
+// KeysOnly はIDのみを設定したFooを返す EntityQuery を返す
+func (q *EntityQuery) KeysOnly() *EntityQuery {
+	newQ := *q
+	newQ.keysOnly = true
+	return &newQ
+}
+
+// Err はクエリの組み立て中に発生したエラーを返す
+func (q *EntityQuery) Err() error {
+	return q.err
+}
+
+var filterOps = []string{"<=", ">=", "<", ">", "="}
+
+func parseFilter(filterStr, value string) (Filter, error) {
+	s := strings.TrimSpace(filterStr)
+	for _, op := range filterOps {
+		if strings.HasSuffix(s, op) {
+			return Filter{
+				Property: strings.TrimSpace(strings.TrimSuffix(s, op)),
+				Op:       op,
+				Value:    value,
+			}, nil
+		}
+	}
+	return Filter{}, fmt.Errorf("foosearch: invalid filter: %q", filterStr)
+}
+
+// propertyValues はFooのプロパティの値を返す
+func propertyValues(f *Foo, property string) ([]string, error) {
+	switch property {
+	case "FamilyName":
+		return []string{f.FamilyName}, nil
+	case "GivenName":
+		return []string{f.GivenName}, nil
+	case "Email":
+		return []string{f.Email}, nil
+	case "FamilyNameKana":
+		return []string{f.FamilyNameKana}, nil
+	case "GivenNameKana":
+		return []string{f.GivenNameKana}, nil
+	case "Search":
+		return f.Search, nil
+	}
+	return nil, fmt.Errorf("foosearch: unknown property: %q", property)
+}
+
+// errIterator は常にエラーを返す Iterator
+type errIterator struct {
+	err error
+}
+
+func (t errIterator) Next() (*Foo, error) {
+	return nil, t.err
+}
+
+func (t errIterator) Cursor() (string, error) {
+	return "", t.err
+}
+
+// orphanIDs は GetMulti のエラーから、エンティティが存在しなかったIDを抽出する
+//
+// エンティティが存在しなかった以外のエラーが含まれていた場合はそのエラーを返す。
+func orphanIDs(ids []int64, err error) ([]int64, error) {
+	if err == nil {
+		return nil, nil
+	}
+	merr, ok := err.(appengine.MultiError)
+	if !ok {
+		return nil, err
+	}
+
+	var orphans []int64
+	for i, e := range merr {
+		switch e {
+		case nil:
+		case datastore.ErrNoSuchEntity:
+			orphans = append(orphans, ids[i])
+		default:
+			return nil, err
+		}
+	}
+	return orphans, nil
+}
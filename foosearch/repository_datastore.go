@@ -0,0 +1,256 @@
+package foosearch
+
+import (
+	"reflect"
+
+	"golang.org/x/net/context"
+	"google.golang.org/appengine"
+	"google.golang.org/appengine/datastore"
+)
+
+// DatastoreRepository はDatastoreをバックエンドとした Repository
+type DatastoreRepository struct {
+	Kind string
+	// NoIndex を指定した場合、Searchプロパティ以外のインデックスを作成しない
+	NoIndex bool
+}
+
+// NewDatastoreRepository は指定したKindにFooを保存する DatastoreRepository を返す
+func NewDatastoreRepository(kind string) *DatastoreRepository {
+	return &DatastoreRepository{Kind: kind}
+}
+
+// fooEntity はFooをDatastoreに保存するための PropertyLoadSaver
+type fooEntity struct {
+	foo     *Foo
+	noIndex bool
+}
+
+func (e *fooEntity) Load(property []datastore.Property) error {
+	// Searchプロパティはデータ取得時の際には不要なため設定を省略
+	// 各項目の値は検索結果の検証にも使用するため、インデックスを作成していない場合でも読み込む
+	for _, p := range property {
+		switch p.Name {
+		case "FamilyName":
+			e.foo.FamilyName = p.Value.(string)
+		case "GivenName":
+			e.foo.GivenName = p.Value.(string)
+		case "Email":
+			e.foo.Email = p.Value.(string)
+		case "FamilyNameKana":
+			e.foo.FamilyNameKana = p.Value.(string)
+		case "GivenNameKana":
+			e.foo.GivenNameKana = p.Value.(string)
+		}
+	}
+	return nil
+}
+
+func (e *fooEntity) Save() ([]datastore.Property, error) {
+	p := []datastore.Property{
+		datastore.Property{
+			Name:    "FamilyName",
+			Value:   e.foo.FamilyName,
+			NoIndex: e.noIndex,
+		},
+		datastore.Property{
+			Name:    "GivenName",
+			Value:   e.foo.GivenName,
+			NoIndex: e.noIndex,
+		},
+		datastore.Property{
+			Name:    "Email",
+			Value:   e.foo.Email,
+			NoIndex: e.noIndex,
+		},
+		datastore.Property{
+			Name:    "FamilyNameKana",
+			Value:   e.foo.FamilyNameKana,
+			NoIndex: e.noIndex,
+		},
+		datastore.Property{
+			Name:    "GivenNameKana",
+			Value:   e.foo.GivenNameKana,
+			NoIndex: e.noIndex,
+		},
+	}
+	for _, s := range e.foo.Search {
+		prop := datastore.Property{
+			Name:     "Search",
+			Value:    s,
+			Multiple: true,
+		}
+		p = append(p, prop)
+	}
+	return p, nil
+}
+
+func (r *DatastoreRepository) key(ctx context.Context, id int64) *datastore.Key {
+	if id == 0 {
+		return datastore.NewIncompleteKey(ctx, r.Kind, nil)
+	}
+	return datastore.NewKey(ctx, r.Kind, "", id, nil)
+}
+
+// Get はIDを指定してFooを取得する
+func (r *DatastoreRepository) Get(ctx context.Context, id int64) (*Foo, error) {
+	f := &Foo{ID: id}
+	if err := datastore.Get(ctx, r.key(ctx, id), &fooEntity{foo: f}); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// GetMulti は複数のIDを指定してFooを取得する
+//
+// 取得できなかったFooはnilとなり、エラーとして appengine.MultiError を返す。
+func (r *DatastoreRepository) GetMulti(ctx context.Context, ids []int64) ([]*Foo, error) {
+	var (
+		keys     = make([]*datastore.Key, len(ids))
+		foos     = make([]*Foo, len(ids))
+		entities = make([]*fooEntity, len(ids))
+	)
+	for i, id := range ids {
+		keys[i] = r.key(ctx, id)
+		foos[i] = &Foo{ID: id}
+		entities[i] = &fooEntity{foo: foos[i]}
+	}
+
+	err := datastore.GetMulti(ctx, keys, entities)
+	if merr, ok := err.(appengine.MultiError); ok {
+		for i, e := range merr {
+			if e != nil {
+				foos[i] = nil
+			}
+		}
+	}
+	return foos, err
+}
+
+// PutMulti はFooを保存し、採番されたIDを設定する
+func (r *DatastoreRepository) PutMulti(ctx context.Context, foos []*Foo) ([]int64, error) {
+	var (
+		keys     = make([]*datastore.Key, len(foos))
+		entities = make([]*fooEntity, len(foos))
+	)
+	for i, f := range foos {
+		keys[i] = r.key(ctx, f.ID)
+		entities[i] = &fooEntity{foo: f, noIndex: r.NoIndex}
+	}
+
+	newKeys, err := datastore.PutMulti(ctx, keys, entities)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]int64, len(newKeys))
+	for i, key := range newKeys {
+		ids[i] = key.IntID()
+		foos[i].ID = ids[i]
+	}
+	return ids, nil
+}
+
+// DeleteMulti はFooを削除する
+func (r *DatastoreRepository) DeleteMulti(ctx context.Context, ids []int64) error {
+	keys := make([]*datastore.Key, len(ids))
+	for i, id := range ids {
+		keys[i] = r.key(ctx, id)
+	}
+	return datastore.DeleteMulti(ctx, keys)
+}
+
+// GetAll は条件に一致するFooをすべて取得する
+func (r *DatastoreRepository) GetAll(ctx context.Context, q *EntityQuery) ([]*Foo, error) {
+	var (
+		foos []*Foo
+		t    = r.Run(ctx, q)
+	)
+	for {
+		f, err := t.Next()
+		if err == datastore.Done {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		foos = append(foos, f)
+	}
+	return foos, nil
+}
+
+// Run はクエリを実行し、結果を取得する Iterator を返す
+func (r *DatastoreRepository) Run(ctx context.Context, q *EntityQuery) Iterator {
+	if err := q.Err(); err != nil {
+		return errIterator{err}
+	}
+
+	dq := datastore.NewQuery(r.Kind)
+	for _, f := range q.Filters() {
+		dq = dq.Filter(f.Property+" "+f.Op, f.Value)
+	}
+	for _, o := range q.Orders() {
+		dq = dq.Order(o)
+	}
+	if q.limit > 0 {
+		dq = dq.Limit(q.limit)
+	}
+	if q.keysOnly {
+		dq = dq.KeysOnly()
+	}
+	if q.start != "" {
+		c, err := datastore.DecodeCursor(q.start)
+		if err != nil {
+			return errIterator{&InvalidQueryError{Reason: "invalid cursor"}}
+		}
+		dq = dq.Start(c)
+	}
+	return &datastoreIterator{t: dq.Run(ctx), keysOnly: q.keysOnly}
+}
+
+type datastoreIterator struct {
+	t        *datastore.Iterator
+	keysOnly bool
+}
+
+func (t *datastoreIterator) Next() (*Foo, error) {
+	var (
+		f   = new(Foo)
+		dst interface{}
+	)
+	if !t.keysOnly {
+		dst = &fooEntity{foo: f}
+	}
+	key, err := t.t.Next(dst)
+	if err != nil {
+		return nil, needIndexError(err)
+	}
+	f.ID = key.IntID()
+	return f, nil
+}
+
+func (t *datastoreIterator) Cursor() (string, error) {
+	c, err := t.t.Cursor()
+	if err != nil {
+		return "", err
+	}
+	return c.String(), nil
+}
+
+// datastoreNeedIndex はインデックス不足を表すDatastoreのエラーコード(datastore_v3 の Error_NEED_INDEX)
+const datastoreNeedIndex = 4
+
+// needIndexError は定義されていない複合インデックスが必要なクエリのエラーを InvalidQueryError に変換する
+//
+// APIのエラーは appengine の internal パッケージの APIError で返され、型を参照できないため、
+// 公開されているフィールドのサービス名とエラーコードをリフレクションで取得して判定する。
+func needIndexError(err error) error {
+	v := reflect.Indirect(reflect.ValueOf(err))
+	if v.Kind() != reflect.Struct {
+		return err
+	}
+	service, code := v.FieldByName("Service"), v.FieldByName("Code")
+	if service.Kind() == reflect.String && service.String() == "datastore_v3" &&
+		code.Kind() == reflect.Int32 && code.Int() == datastoreNeedIndex {
+		return &InvalidQueryError{Reason: "the combination of search conditions and sort orders is not supported (" + err.Error() + ")"}
+	}
+	return err
+}
@@ -0,0 +1,257 @@
+package foosearch
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"golang.org/x/net/context"
+	"google.golang.org/appengine"
+	"google.golang.org/appengine/datastore"
+)
+
+// MemoryRepository はメモリ上にFooを保持する Repository
+//
+// Datastoreを利用せずに検索処理を動かすためのもので、等価フィルタ、比較フィルタ、
+// 複数値プロパティ(Search)に対する等価フィルタをDatastoreと同じ意味で評価する。
+type MemoryRepository struct {
+	mu     sync.RWMutex
+	foos   map[int64]*Foo
+	lastID int64
+}
+
+// NewMemoryRepository は空の MemoryRepository を返す
+func NewMemoryRepository() *MemoryRepository {
+	return &MemoryRepository{foos: make(map[int64]*Foo)}
+}
+
+// Get はIDを指定してFooを取得する
+func (r *MemoryRepository) Get(ctx context.Context, id int64) (*Foo, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	f, ok := r.foos[id]
+	if !ok {
+		return nil, datastore.ErrNoSuchEntity
+	}
+	return loaded(f), nil
+}
+
+// GetMulti は複数のIDを指定してFooを取得する
+//
+// 取得できなかったFooはnilとなり、エラーとして appengine.MultiError を返す。
+func (r *MemoryRepository) GetMulti(ctx context.Context, ids []int64) ([]*Foo, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var (
+		foos    = make([]*Foo, len(ids))
+		merr    = make(appengine.MultiError, len(ids))
+		missing = false
+	)
+	for i, id := range ids {
+		f, ok := r.foos[id]
+		if !ok {
+			merr[i] = datastore.ErrNoSuchEntity
+			missing = true
+			continue
+		}
+		foos[i] = loaded(f)
+	}
+	if missing {
+		return foos, merr
+	}
+	return foos, nil
+}
+
+// PutMulti はFooを保存し、採番されたIDを設定する
+func (r *MemoryRepository) PutMulti(ctx context.Context, foos []*Foo) ([]int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	ids := make([]int64, len(foos))
+	for i, f := range foos {
+		if f.ID == 0 {
+			r.lastID++
+			f.ID = r.lastID
+		} else if f.ID > r.lastID {
+			r.lastID = f.ID
+		}
+		stored := *f
+		stored.Search = append([]string(nil), f.Search...)
+		r.foos[f.ID] = &stored
+		ids[i] = f.ID
+	}
+	return ids, nil
+}
+
+// DeleteMulti はFooを削除する
+func (r *MemoryRepository) DeleteMulti(ctx context.Context, ids []int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, id := range ids {
+		delete(r.foos, id)
+	}
+	return nil
+}
+
+// GetAll は条件に一致するFooを並び順の指定に従って取得する
+// DatastoreRepository と同様に、開始位置、件数の上限、キーのみの指定に従う
+func (r *MemoryRepository) GetAll(ctx context.Context, q *EntityQuery) ([]*Foo, error) {
+	t := r.Run(ctx, q)
+	var foos []*Foo
+	for {
+		f, err := t.Next()
+		if err == datastore.Done {
+			return foos, nil
+		} else if err != nil {
+			return nil, err
+		}
+		foos = append(foos, f)
+	}
+}
+
+// matching は条件に一致するFooを並び順の指定に従ってすべて返す
+func (r *MemoryRepository) matching(q *EntityQuery) ([]*Foo, error) {
+	if err := q.Err(); err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var foos []*Foo
+	for _, f := range r.foos {
+		ok, err := matchFilters(f, q.Filters())
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			foos = append(foos, loaded(f))
+		}
+	}
+	// Datastoreでは順序を指定しない場合はキーの順で返されるため、それに合わせて最後はID順に並べる
+	orders, err := memoryOrders(q.Orders())
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(foos, func(i, j int) bool { return compareFoos(foos[i], foos[j], orders) < 0 })
+	return foos, nil
+}
+
+// memoryOrders は EntityQuery の並び順を SortOrder に変換する
+func memoryOrders(fieldNames []string) ([]SortOrder, error) {
+	var orders []SortOrder
+	for _, name := range fieldNames {
+		o := SortOrder{Property: strings.TrimPrefix(name, "-"), Descending: strings.HasPrefix(name, "-")}
+		if o.Property == "__key__" {
+			// キーの順は比較の最後に必ず使用されるため、ここでは何もしない
+			continue
+		}
+		if _, err := propertyValues(&Foo{}, o.Property); err != nil {
+			return nil, err
+		}
+		orders = append(orders, o)
+	}
+	return orders, nil
+}
+
+// Run はクエリを実行し、結果を取得する Iterator を返す
+//
+// カーソルは結果の先頭からの位置を表すため、クエリの実行の間にデータが更新された場合は結果がずれることがある。
+func (r *MemoryRepository) Run(ctx context.Context, q *EntityQuery) Iterator {
+	offset, err := decodeOffsetCursor(q.start)
+	if err != nil {
+		return errIterator{err}
+	}
+	foos, err := r.matching(q)
+	if err != nil {
+		return errIterator{err}
+	}
+
+	if offset > len(foos) {
+		offset = len(foos)
+	}
+	foos = foos[offset:]
+	if q.limit > 0 && q.limit < len(foos) {
+		foos = foos[:q.limit]
+	}
+	if q.keysOnly {
+		for i, f := range foos {
+			foos[i] = &Foo{ID: f.ID}
+		}
+	}
+	return &memoryIterator{foos: foos, offset: offset}
+}
+
+type memoryIterator struct {
+	foos   []*Foo
+	offset int
+	pos    int
+}
+
+func (t *memoryIterator) Next() (*Foo, error) {
+	if t.pos >= len(t.foos) {
+		return nil, datastore.Done
+	}
+	f := t.foos[t.pos]
+	t.pos++
+	return f, nil
+}
+
+func (t *memoryIterator) Cursor() (string, error) {
+	return encodeOffsetCursor(t.offset + t.pos), nil
+}
+
+// loaded はDatastoreから取得した場合と同じく、Searchプロパティを除いたFooを返す
+func loaded(f *Foo) *Foo {
+	c := *f
+	c.Search = nil
+	return &c
+}
+
+// matchFilters はFooがすべての条件を満たすかを判定する
+//
+// 複数値プロパティの場合、Datastoreと同様にいずれかの値が条件を満たせば一致とみなす。
+func matchFilters(f *Foo, filters []Filter) (bool, error) {
+	for _, filter := range filters {
+		values, err := propertyValues(f, filter.Property)
+		if err != nil {
+			return false, err
+		}
+
+		matched := false
+		for _, v := range values {
+			ok, err := compare(v, filter.Op, filter.Value)
+			if err != nil {
+				return false, err
+			}
+			if ok {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+func compare(v, op, value string) (bool, error) {
+	switch op {
+	case "=":
+		return v == value, nil
+	case ">=":
+		return v >= value, nil
+	case "<=":
+		return v <= value, nil
+	case ">":
+		return v > value, nil
+	case "<":
+		return v < value, nil
+	}
+	return false, fmt.Errorf("foosearch: unknown operator: %q", op)
+}
@@ -0,0 +1,55 @@
+package foosearch
+
+import (
+	"reflect"
+	"testing"
+
+	"golang.org/x/net/context"
+)
+
+func TestMemoryRepositoryGetAll(t *testing.T) {
+	tests := []struct {
+		name     string
+		query    *EntityQuery
+		want     []int64
+		keysOnly bool
+	}{
+		{
+			name:  "filter",
+			query: NewEntityQuery().Filter("FamilyName=", "鈴木"),
+			want:  []int64{3, 4},
+		},
+		{
+			name:  "order and limit",
+			query: NewEntityQuery().Order("-FamilyName").Limit(2),
+			want:  []int64{3, 4},
+		},
+		{
+			name:     "start, limit and keys only",
+			query:    NewEntityQuery().Filter("FamilyName>=", "山").Order("FamilyName").Start(encodeOffsetCursor(1)).Limit(3).KeysOnly(),
+			want:     []int64{7, 1, 2},
+			keysOnly: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewMemoryRepository()
+			putFoos(t, r)
+
+			foos, err := r.GetAll(context.Background(), tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []int64
+			for _, f := range foos {
+				got = append(got, f.ID)
+				if keysOnly := reflect.DeepEqual(f, &Foo{ID: f.ID}); keysOnly != tt.keysOnly {
+					t.Errorf("GetAll() returned %+v, want keys only = %v", f, tt.keysOnly)
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("GetAll() IDs = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,153 @@
+package foosearch
+
+import (
+	"bytes"
+	"strings"
+)
+
+// romajiTable はひらがなとヘボン式のローマ字の対応
+var romajiTable = map[string]string{
+	"あ": "a", "い": "i", "う": "u", "え": "e", "お": "o",
+	"か": "ka", "き": "ki", "く": "ku", "け": "ke", "こ": "ko",
+	"が": "ga", "ぎ": "gi", "ぐ": "gu", "げ": "ge", "ご": "go",
+	"さ": "sa", "し": "shi", "す": "su", "せ": "se", "そ": "so",
+	"ざ": "za", "じ": "ji", "ず": "zu", "ぜ": "ze", "ぞ": "zo",
+	"た": "ta", "ち": "chi", "つ": "tsu", "て": "te", "と": "to",
+	"だ": "da", "ぢ": "ji", "づ": "zu", "で": "de", "ど": "do",
+	"な": "na", "に": "ni", "ぬ": "nu", "ね": "ne", "の": "no",
+	"は": "ha", "ひ": "hi", "ふ": "fu", "へ": "he", "ほ": "ho",
+	"ば": "ba", "び": "bi", "ぶ": "bu", "べ": "be", "ぼ": "bo",
+	"ぱ": "pa", "ぴ": "pi", "ぷ": "pu", "ぺ": "pe", "ぽ": "po",
+	"ま": "ma", "み": "mi", "む": "mu", "め": "me", "も": "mo",
+	"や": "ya", "ゆ": "yu", "よ": "yo",
+	"ら": "ra", "り": "ri", "る": "ru", "れ": "re", "ろ": "ro",
+	"わ": "wa", "ゐ": "i", "ゑ": "e", "を": "o", "ん": "n",
+	"ぁ": "a", "ぃ": "i", "ぅ": "u", "ぇ": "e", "ぉ": "o",
+	"ゃ": "ya", "ゅ": "yu", "ょ": "yo", "ゎ": "wa", "ゔ": "vu",
+
+	"きゃ": "kya", "きゅ": "kyu", "きょ": "kyo",
+	"ぎゃ": "gya", "ぎゅ": "gyu", "ぎょ": "gyo",
+	"しゃ": "sha", "しゅ": "shu", "しょ": "sho", "しぇ": "she",
+	"じゃ": "ja", "じゅ": "ju", "じょ": "jo", "じぇ": "je",
+	"ちゃ": "cha", "ちゅ": "chu", "ちょ": "cho", "ちぇ": "che",
+	"ぢゃ": "ja", "ぢゅ": "ju", "ぢょ": "jo",
+	"にゃ": "nya", "にゅ": "nyu", "にょ": "nyo",
+	"ひゃ": "hya", "ひゅ": "hyu", "ひょ": "hyo",
+	"びゃ": "bya", "びゅ": "byu", "びょ": "byo",
+	"ぴゃ": "pya", "ぴゅ": "pyu", "ぴょ": "pyo",
+	"みゃ": "mya", "みゅ": "myu", "みょ": "myo",
+	"りゃ": "rya", "りゅ": "ryu", "りょ": "ryo",
+	"ふぁ": "fa", "ふぃ": "fi", "ふぇ": "fe", "ふぉ": "fo",
+	"てぃ": "ti", "でぃ": "di", "とぅ": "tu", "どぅ": "du",
+	"うぃ": "wi", "うぇ": "we", "うぉ": "wo",
+	"ゔぁ": "va", "ゔぃ": "vi", "ゔぇ": "ve", "ゔぉ": "vo",
+}
+
+// kanaNormalizer はローマ字に変換する前にカタカナや半角カナをひらがなに統一する
+var kanaNormalizer = &Normalizer{Kana: true}
+
+// romaji はかなをヘボン式のローマ字に変換する
+//
+// 長音は「たろう」を「tarou」のように母音を重ねて表記するものと、
+// 「taro」のように省略して表記するものの両方を返す。かな以外の文字はそのまま残す。
+func romaji(kana string) []string {
+	if kana == "" {
+		return nil
+	}
+
+	var (
+		runes = []rune(kanaNormalizer.Normalize(kana))
+		// full は長音を母音を重ねて表記したもの、short は長音を省略したもの
+		full, short bytes.Buffer
+		sokuon      bool
+	)
+	for i := 0; i < len(runes); {
+		var (
+			syl string
+			n   = 1
+		)
+		if i+1 < len(runes) {
+			if s, ok := romajiTable[string(runes[i:i+2])]; ok {
+				syl, n = s, 2
+			}
+		}
+		if syl == "" {
+			switch runes[i] {
+			case 'っ':
+				sokuon = true
+				i++
+				continue
+			case 'ー':
+				// 長音記号は直前の母音を重ねる
+				if b := full.Bytes(); len(b) > 0 && isVowel(b[len(b)-1]) {
+					full.WriteByte(b[len(b)-1])
+				}
+				i++
+				continue
+			}
+			if s, ok := romajiTable[string(runes[i])]; ok {
+				syl = s
+			} else {
+				syl = string(runes[i])
+			}
+		}
+		i += n
+
+		// 促音は次の子音を重ねる。「ち」の前は「tch」とする
+		if sokuon && syl[0] >= 'a' && syl[0] <= 'z' && !isVowel(syl[0]) {
+			if strings.HasPrefix(syl, "ch") {
+				syl = "t" + syl
+			} else {
+				syl = syl[:1] + syl
+			}
+		}
+		sokuon = false
+
+		full.WriteString(syl)
+		if !isLongVowel(short.Bytes(), syl) {
+			short.WriteString(syl)
+		}
+	}
+
+	if full.String() == short.String() {
+		return []string{full.String()}
+	}
+	return []string{full.String(), short.String()}
+}
+
+// isLongVowel は直前の母音に続けて長音として省略できる母音かどうかを判定する
+func isLongVowel(prev []byte, syl string) bool {
+	if len(prev) == 0 {
+		return false
+	}
+	switch last := prev[len(prev)-1]; syl {
+	case "u":
+		return last == 'o' || last == 'u'
+	case "o":
+		return last == 'o'
+	}
+	return false
+}
+
+func isVowel(b byte) bool {
+	return strings.IndexByte("aiueo", b) >= 0
+}
+
+// searchableValues は項目ごとに検索対象となる文字列を返す
+//
+// 読みが設定されている場合は、読みとそのローマ字表記も姓・名の値として扱う。
+func searchableValues(f *Foo) (family, given, email []string) {
+	return withReading(f.FamilyName, f.FamilyNameKana), withReading(f.GivenName, f.GivenNameKana), []string{f.Email}
+}
+
+func withReading(value, kana string) []string {
+	return append([]string{value}, readingValues(kana)...)
+}
+
+// readingValues は読みと、そのローマ字表記を返す
+func readingValues(kana string) []string {
+	if kana == "" {
+		return nil
+	}
+	return append([]string{kana}, romaji(kana)...)
+}
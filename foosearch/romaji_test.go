@@ -0,0 +1,27 @@
+package foosearch
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRomaji(t *testing.T) {
+	tests := []struct {
+		kana string
+		want []string
+	}{
+		{"たなか", []string{"tanaka"}},
+		{"タナカ", []string{"tanaka"}},
+		{"たろう", []string{"tarou", "taro"}},
+		{"おおの", []string{"oono", "ono"}},
+		{"きょうこ", []string{"kyouko", "kyoko"}},
+		{"がっこう", []string{"gakkou", "gakko"}},
+		{"しんいち", []string{"shinichi"}},
+		{"ちゃーはん", []string{"chaahan", "chahan"}},
+	}
+	for _, tt := range tests {
+		if got := romaji(tt.kana); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("romaji(%q) = %q, want %q", tt.kana, got, tt.want)
+		}
+	}
+}
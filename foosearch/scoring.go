@@ -0,0 +1,251 @@
+package foosearch
+
+import (
+	"math"
+	"sort"
+	"strings"
+
+	"golang.org/x/net/context"
+	"google.golang.org/appengine/datastore"
+	"google.golang.org/appengine/log"
+)
+
+// docCountToken はトークンごとの件数とあわせて、保存されているFooの件数を記録するためのキー
+// トークンは必ず "<プレフィックス> <文字列>" の形式となるため、トークンと重複することはない
+const docCountToken = "#"
+
+// Scoring はNGram検索の結果を、検索ワードとの関連度の高い順に並び替えるための設定
+//
+// 検索ワードのトークンごとに、項目に含まれる回数と項目の長さからBM25のスコアを計算し、項目の重みをかけて合計する。
+// さらに、項目の値が検索ワードと完全に一致する場合と検索ワードで始まる場合は、項目の重みをかけたボーナスを加算する。
+// トークンの希少さ(IDF)は NGramSearcher.Stats の件数から計算し、Stats が設定されていない場合はすべてのトークンを同じ重みとする。
+type Scoring struct {
+	// FamilyName、GivenName、Email は項目ごとの重み
+	FamilyName float64
+	GivenName  float64
+	Email      float64
+	// ExactBonus は項目の値が検索ワードと完全に一致した場合のボーナス
+	ExactBonus float64
+	// PrefixBonus は項目の値が検索ワードで始まる場合のボーナス
+	PrefixBonus float64
+	// K1、B はBM25のパラメータ。K1 は回数による重みの上限、B は項目の長さによる補正の強さ
+	K1 float64
+	B  float64
+}
+
+// NewScoring は姓、名、メールアドレスの順に重みをつけた Scoring を返す
+func NewScoring() *Scoring {
+	return &Scoring{
+		FamilyName:  3,
+		GivenName:   2,
+		Email:       1,
+		ExactBonus:  2,
+		PrefixBonus: 1,
+		K1:          1.2,
+		B:           0.75,
+	}
+}
+
+// scoredTerm は順位付けに使用する検索ワードと、そのトークン
+type scoredTerm struct {
+	word  string
+	field ngramField
+	// tokens はプレフィックスのないトークン
+	tokens []string
+	// targets はスコアを計算する項目(0: 姓、1: 名、2: メールアドレス)
+	targets []int
+}
+
+func (s *NGramSearcher) scoredTerms(q Query) []scoredTerm {
+	all, family, given, email := s.fields()
+
+	var terms []scoredTerm
+	add := func(word string, f ngramField, targets ...int) {
+		if word == "" {
+			return
+		}
+		terms = append(terms, scoredTerm{
+			word:    word,
+			field:   f,
+			tokens:  uniqueTokens(f.tokenizer.QueryTokens(word)),
+			targets: targets,
+		})
+	}
+	// `q` パラメータはすべての項目でスコアを計算する
+	add(q.Text, all, 0, 1, 2)
+	add(q.FamilyName, family, 0)
+	add(q.GivenName, given, 1)
+	add(q.Email, email, 2)
+	return terms
+}
+
+// rankedPage はクエリの結果を MaxScan 件まで読み込み、match を満たすFooを並び順の指定の順、
+// 指定がない場合はスコアの高い順に並び替えたうえで、カーソルの位置から最大limit件取得する
+//
+// 順位はすべての候補を読み込まないと決まらないため、カーソルには先頭からの件数を使用し、ページごとに候補を読み込み直す。
+// 候補が MaxScan 件を超える場合は、読み込んだ候補の中でのみ順位付けし、Response.Truncated を設定する。
+func (s *NGramSearcher) rankedPage(ctx context.Context, eq *EntityQuery, q Query, match func(*Foo) bool) (*Response, error) {
+	offset, err := decodeOffsetCursor(q.Cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	// 候補が上限を超えるかを判定するために1件多く取得する
+	var (
+		max       = maxScan(s.MaxScan)
+		t         = s.Repository.Run(ctx, eq.Limit(max+1))
+		foos      []*Foo
+		rejected  int
+		truncated bool
+	)
+	for scanned := 0; ; scanned++ {
+		f, err := t.Next()
+		if err == datastore.Done {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		if scanned == max {
+			truncated = true
+			break
+		}
+		if !match(f) {
+			rejected++
+			continue
+		}
+		foos = append(foos, f)
+	}
+
+	var results []Result
+	if len(q.Sort) != 0 {
+		results = sortResults(foos, q.Sort)
+	} else {
+		results = s.rank(ctx, foos, q)
+	}
+	if offset > len(results) {
+		offset = len(results)
+	}
+	// 順位付けのために読み込んだ候補をそのまま集計する
+	resp := &Response{Rejected: rejected, Truncated: truncated, Facets: countFacets(foos, q.Facets)}
+	if end := offset + pageLimit(q.Limit); end < len(results) {
+		resp.Items = results[offset:end]
+		resp.NextCursor, resp.HasMore = encodeOffsetCursor(end), true
+	} else {
+		resp.Items = results[offset:]
+	}
+	return resp, nil
+}
+
+// rank はFooごとのスコアを計算し、スコアの高い順に並び替えた検索結果を返す
+// スコアが同じ場合はキーの順とする
+func (s *NGramSearcher) rank(ctx context.Context, foos []*Foo, q Query) []Result {
+	var (
+		sc     = s.Scoring
+		boosts = []float64{sc.FamilyName, sc.GivenName, sc.Email}
+		terms  = s.scoredTerms(q)
+		idf    = s.idf(ctx, terms)
+		scores = make([]float64, len(foos))
+		values = make([][3][]string, len(foos))
+	)
+	for i, f := range foos {
+		family, given, email := searchableValues(s.Normalizer.normalizeFoo(f))
+		values[i] = [3][]string{family, given, email}
+	}
+
+	for _, term := range terms {
+		for _, target := range term.targets {
+			// 項目の長さ(トークン数)の平均は、候補のFooの中で計算する
+			var (
+				counts = make([]map[string]int, len(foos))
+				length = make([]int, len(foos))
+				total  int
+			)
+			for i := range foos {
+				counts[i] = make(map[string]int)
+				for _, v := range values[i][target] {
+					for _, t := range term.field.tokenizer.Tokenize(v) {
+						counts[i][t]++
+						length[i]++
+					}
+				}
+				total += length[i]
+			}
+			if total == 0 {
+				continue
+			}
+			avg := float64(total) / float64(len(foos))
+
+			for i := range foos {
+				norm := sc.K1 * (1 - sc.B + sc.B*float64(length[i])/avg)
+				for _, t := range term.tokens {
+					tf := float64(counts[i][t])
+					if tf == 0 {
+						continue
+					}
+					w := 1.0
+					if v, ok := idf[term.field.prefix+" "+t]; ok {
+						w = v
+					}
+					scores[i] += boosts[target] * w * tf * (sc.K1 + 1) / (tf + norm)
+				}
+				scores[i] += boosts[target] * sc.bonus(values[i][target], term.word)
+			}
+		}
+	}
+
+	results := make([]Result, len(foos))
+	for i, f := range foos {
+		results[i] = Result{Foo: f, Score: scores[i]}
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].ID < results[j].ID
+	})
+	return results
+}
+
+// bonus は項目の値が検索ワードと完全に一致する、または検索ワードで始まる場合のボーナスを返す
+// 値が複数ある場合(読みやローマ字表記)は、最も高いボーナスとする
+func (sc *Scoring) bonus(values []string, word string) float64 {
+	var bonus float64
+	for _, v := range values {
+		if v == word {
+			return sc.ExactBonus
+		}
+		if strings.HasPrefix(v, word) {
+			bonus = sc.PrefixBonus
+		}
+	}
+	return bonus
+}
+
+// idf はトークンごとの件数から、プレフィックス付きのトークンごとのIDFを計算する
+// 件数が取得できない場合はnilを返し、すべてのトークンを同じ重みとする
+func (s *NGramSearcher) idf(ctx context.Context, terms []scoredTerm) map[string]float64 {
+	if s.Stats == nil {
+		return nil
+	}
+	tokens := []string{docCountToken}
+	for _, term := range terms {
+		tokens = appendWithPrefix(tokens, term.field.prefix, term.tokens)
+	}
+	counts, err := s.Stats.Counts(ctx, uniqueTokens(tokens))
+	if err != nil {
+		// 件数が取得できなくても順位付けはできるため、同じ重みとして扱う
+		log.Warningf(ctx, "failed to get token stats; error: %#v", err)
+		return nil
+	}
+	n := float64(counts[docCountToken])
+	if n <= 0 {
+		return nil
+	}
+
+	idf := make(map[string]float64, len(tokens))
+	for _, t := range tokens[1:] {
+		df := math.Min(math.Max(float64(counts[t]), 0), n)
+		idf[t] = math.Log(1 + (n-df+0.5)/(df+0.5))
+	}
+	return idf
+}
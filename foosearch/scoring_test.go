@@ -0,0 +1,70 @@
+package foosearch
+
+import (
+	"reflect"
+	"testing"
+
+	"golang.org/x/net/context"
+)
+
+func TestNGramSearcherScoring(t *testing.T) {
+	tests := []struct {
+		query Query
+		want  []string
+	}{
+		// 姓に完全一致するものを、名に完全一致するものより上位とする
+		{Query{Text: "鈴木"}, []string{"鈴木一郎", "鈴木次郎", "一郎鈴木"}},
+		{Query{Text: "メロ"}, []string{"メロン太郎", "ロンメロ太郎"}},
+		// 並び順を指定した場合は順位付けを行わない
+		{Query{Text: "鈴木", Sort: []SortOrder{{Property: "Email", Descending: true}}}, []string{"鈴木次郎", "鈴木一郎", "一郎鈴木"}},
+	}
+	for _, tt := range tests {
+		s := NewNGramSearcher(NewMemoryRepository())
+		s.Stats = NewMemoryTokenStats()
+		s.Scoring = NewScoring()
+		putFoos(t, s)
+
+		resp, err := s.Search(context.Background(), tt.query)
+		if err != nil {
+			t.Errorf("Search(%+v) error = %v", tt.query, err)
+			continue
+		}
+		if got := fullNames(resp.Items); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Search(%+v) = %v, want %v", tt.query, got, tt.want)
+		}
+		if len(tt.query.Sort) != 0 {
+			continue
+		}
+		for i, r := range resp.Items {
+			if r.Score <= 0 || i > 0 && r.Score > resp.Items[i-1].Score {
+				t.Errorf("Search(%+v) scores are not descending: %v", tt.query, resp.Items)
+				break
+			}
+		}
+	}
+}
+
+func TestNGramSearcherScoringTruncated(t *testing.T) {
+	tests := []struct {
+		maxScan   int
+		truncated bool
+		items     int
+	}{
+		{2, true, 2},
+		{4, false, 4},
+	}
+	for _, tt := range tests {
+		s := NewNGramSearcher(NewMemoryRepository())
+		s.Scoring = NewScoring()
+		s.MaxScan = tt.maxScan
+		putFoos(t, s)
+
+		resp, err := s.Search(context.Background(), Query{Text: "太郎"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.Truncated != tt.truncated || len(resp.Items) != tt.items {
+			t.Errorf("MaxScan %d: Truncated = %v, items = %d, want %v, %d", tt.maxScan, resp.Truncated, len(resp.Items), tt.truncated, tt.items)
+		}
+	}
+}
@@ -0,0 +1,613 @@
+package foosearch
+
+import (
+	"html"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"golang.org/x/net/context"
+	"google.golang.org/appengine"
+	"google.golang.org/appengine/datastore"
+	"google.golang.org/appengine/log"
+	"google.golang.org/appengine/search"
+	"google.golang.org/appengine/taskqueue"
+)
+
+// Search APIのインデックスを更新するタスクのエンドポイント
+const (
+	// IndexTaskPath はインデックスの作成・更新を行うタスクのエンドポイント
+	IndexTaskPath = "/backend/foos/index"
+	// UnindexTaskPath はインデックスの削除を行うタスクのエンドポイント
+	UnindexTaskPath = "/backend/foos/unindex"
+)
+
+// SearchAPISearcher はSearch APIを検索インデックスとして利用し、実データはDatastoreから取得する
+type SearchAPISearcher struct {
+	Repository
+	Index string
+	// Tokenizer はインデックス作成時に各項目の文字列をトークナイズする
+	// トークンは空白区切りでSearch APIのドキュメントに登録する
+	Tokenizer Tokenizer
+	// Normalizer はインデックス作成時と検索時に文字列を正規化する。nil の場合は正規化しない
+	Normalizer *Normalizer
+	// Ranking は検索結果の順位付けの設定。nil の場合はSearch APIのデフォルトの順に返す
+	// 並び順が指定された場合は、並び順を優先する
+	Ranking *SearchAPIRanking
+}
+
+type fooIndex struct {
+	FamilyName string
+	GivenName  string
+	Email      string
+	// FamilyNameKana と GivenNameKana は読みとそのローマ字表記
+	FamilyNameKana string
+	GivenNameKana  string
+	// Local と Domain はメールアドレスのローカル部とドメインのトークン
+	// "local:suzuki" や "domain:sample.com" で完全一致で検索できるよう、それぞれ local、domain という名前のAtomフィールドとして登録する
+	Local  []string
+	Domain []string
+	// Boost は順位付けのために、項目の重みの分だけトークンを繰り返した文字列
+	Boost string
+	// FamilyNameExact は姓の完全一致を優先するための、正規化した姓のAtomフィールド
+	FamilyNameExact []string
+	// Facets は値ごとの件数を集計するためのファセット
+	Facets []search.Facet
+	// FamilyNameSort、GivenNameSort、EmailSort は並び替えに使用する、トークナイズしない元の値のAtomフィールド
+	FamilyNameSort search.Atom
+	GivenNameSort  search.Atom
+	EmailSort      search.Atom
+}
+
+// Save はSearch APIのドキュメントのフィールドを返す
+// ローカル部とドメインのトークンは、同じ名前の複数のフィールドとして登録する
+func (x *fooIndex) Save() ([]search.Field, *search.DocumentMetadata, error) {
+	fields := []search.Field{
+		{Name: "FamilyName", Value: x.FamilyName},
+		{Name: "GivenName", Value: x.GivenName},
+		{Name: "Email", Value: x.Email},
+		{Name: "FamilyNameKana", Value: x.FamilyNameKana},
+		{Name: "GivenNameKana", Value: x.GivenNameKana},
+		{Name: "Boost", Value: x.Boost},
+		{Name: "FamilyNameSort", Value: x.FamilyNameSort},
+		{Name: "GivenNameSort", Value: x.GivenNameSort},
+		{Name: "EmailSort", Value: x.EmailSort},
+	}
+	for _, t := range x.Local {
+		fields = append(fields, search.Field{Name: "local", Value: search.Atom(t)})
+	}
+	for _, t := range x.Domain {
+		fields = append(fields, search.Field{Name: "domain", Value: search.Atom(t)})
+	}
+	for _, t := range x.FamilyNameExact {
+		fields = append(fields, search.Field{Name: "FamilyNameExact", Value: search.Atom(t)})
+	}
+	return fields, &search.DocumentMetadata{Facets: x.Facets}, nil
+}
+
+// Load はSearch APIのドキュメントのフィールドを読み込む
+func (x *fooIndex) Load(fields []search.Field, meta *search.DocumentMetadata) error {
+	if meta != nil {
+		x.Facets = meta.Facets
+	}
+	for _, f := range fields {
+		switch v := f.Value.(type) {
+		case string:
+			switch f.Name {
+			case "FamilyName":
+				x.FamilyName = v
+			case "GivenName":
+				x.GivenName = v
+			case "Email":
+				x.Email = v
+			case "FamilyNameKana":
+				x.FamilyNameKana = v
+			case "GivenNameKana":
+				x.GivenNameKana = v
+			case "Boost":
+				x.Boost = v
+			}
+		case search.Atom:
+			switch f.Name {
+			case "local":
+				x.Local = append(x.Local, string(v))
+			case "domain":
+				x.Domain = append(x.Domain, string(v))
+			case "FamilyNameExact":
+				x.FamilyNameExact = append(x.FamilyNameExact, string(v))
+			case "FamilyNameSort":
+				x.FamilyNameSort = v
+			case "GivenNameSort":
+				x.GivenNameSort = v
+			case "EmailSort":
+				x.EmailSort = v
+			}
+		}
+	}
+	return nil
+}
+
+// NewSearchAPISearcher は各項目をそのままインデックスに登録する SearchAPISearcher を返す
+func NewSearchAPISearcher(repo Repository, index string) *SearchAPISearcher {
+	return &SearchAPISearcher{
+		Repository: repo,
+		Index:      index,
+		Tokenizer:  WhitespaceTokenizer{},
+	}
+}
+
+// NewForwardMatchSearchAPISearcher は各項目の前方一致のトークンをインデックスに登録する SearchAPISearcher を返す
+func NewForwardMatchSearchAPISearcher(repo Repository, index string) *SearchAPISearcher {
+	return &SearchAPISearcher{
+		Repository: repo,
+		Index:      index,
+		Tokenizer:  PrefixTokenizer{},
+	}
+}
+
+// Search は `q` パラメータをSearch APIのクエリとして検索を行う
+func (s *SearchAPISearcher) Search(ctx context.Context, q Query) (*Response, error) {
+	// Search APIのクエリには編集距離を指定できないため、あいまい検索はできない
+	if q.Fuzzy != 0 {
+		return nil, &InvalidQueryError{Reason: "fuzzy is not supported by Search API"}
+	}
+
+	index, err := search.Open(s.Index)
+	if err != nil {
+		return nil, err
+	}
+
+	// Search APIで検索を行う
+	// Search APIは検索インデックスとしての用途のみ期待しており、実データはDatastoreから取得するようにするため、
+	// 検索オプションとしてIDsOnlyを指定している。
+	// 続きの有無を判定するために、取得件数より1件多く検索する
+	// 並び順の指定がない場合は、Ranking の設定でスコアの高い順に並び替える
+	limit := pageLimit(q.Limit)
+	query, sort := s.normalizeQuery(q.Text), sortOptions(q.Sort)
+	if sort == nil && s.Ranking != nil {
+		var words []string
+		for _, w := range freeTextWords(q.Text) {
+			words = append(words, strings.TrimSpace(s.Normalizer.Normalize(w)))
+		}
+		query = s.Ranking.query(query, words)
+		sort = s.Ranking.sortOptions()
+	}
+	opts := &search.SearchOptions{
+		IDsOnly: true,
+		Limit:   limit + 1,
+		Cursor:  search.Cursor(q.Cursor),
+		Sort:    sort,
+	}
+	// 検索ワードに一致した部分を強調するため、各項目のスニペットを式で計算して取得する
+	// スニペットの他にはドキュメントのフィールドを使用しないため、取得するフィールドは1つに絞っている
+	if q.Text != "" {
+		opts.IDsOnly = false
+		opts.Fields = []string{"FamilyName"}
+		opts.Expressions = snippetExpressions(s.normalizeQuery(q.Text))
+	}
+	// 値ごとの件数はSearch APIのファセットで集計する
+	for _, name := range q.Facets {
+		opts.Facets = append(opts.Facets, search.FacetDiscovery(name))
+	}
+	if len(opts.Facets) != 0 {
+		opts.Facets = append(opts.Facets, search.FacetDocumentDepth(DefaultMaxScan))
+	}
+	iterator := index.Search(ctx, query, opts)
+	facets, err := searchFacets(iterator, q.Facets)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		ids     []int64
+		matched = make(map[int64]map[string][]highlightWord)
+		next    search.Cursor
+		hasMore bool
+	)
+	// 検索結果の取得
+	for {
+		var dst search.FieldLoadSaver
+		hit := &snippetHit{words: make(map[string][]highlightWord)}
+		if !opts.IDsOnly {
+			dst = hit
+		}
+		sid, err := iterator.Next(dst)
+		if err == search.Done {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		if len(ids) == limit {
+			hasMore = true
+			break
+		}
+		id, _ := strconv.ParseInt(sid, 10, 64)
+		ids = append(ids, id)
+		matched[id] = hit.words
+		next = iterator.Cursor()
+	}
+
+	resp, err := s.hydrate(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	s.highlight(resp, matched)
+	if hasMore {
+		resp.NextCursor = string(next)
+		resp.HasMore = true
+	}
+	resp.Facets = facets
+	return resp, nil
+}
+
+// searchFacets はSearch APIで集計したファセットを項目ごとの値と件数に変換する
+// 集計の対象となるFooがない項目も、空の一覧として返す
+func searchFacets(iterator *search.Iterator, names []string) (map[string][]FacetCount, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+	results, err := iterator.Facets()
+	if err != nil {
+		return nil, err
+	}
+
+	facets := make(map[string][]FacetCount, len(names))
+	for _, name := range names {
+		facets[name] = []FacetCount{}
+	}
+	for _, values := range results {
+		for _, v := range values {
+			if atom, ok := v.Value.(search.Atom); ok {
+				facets[v.Name] = append(facets[v.Name], FacetCount{Value: string(atom), Count: v.Count})
+			}
+		}
+	}
+	for name, counts := range facets {
+		if len(counts) > MaxFacetValues {
+			facets[name] = counts[:MaxFacetValues]
+		}
+	}
+	return facets, nil
+}
+
+// snippetPrefix はスニペットを計算するフィールドの名前の接頭辞
+const snippetPrefix = "snippet"
+
+// snippetExpressions は各項目のスニペットを計算する式を返す
+func snippetExpressions(query string) []search.FieldExpression {
+	query = strings.Replace(query, `\`, `\\`, -1)
+	query = strings.Replace(query, `"`, `\"`, -1)
+	exprs := make([]search.FieldExpression, 0, len(highlightFields))
+	for _, f := range highlightFields {
+		exprs = append(exprs, search.FieldExpression{
+			Name: snippetPrefix + f,
+			Expr: `snippet("` + query + `", ` + f + `)`,
+		})
+	}
+	return exprs
+}
+
+// snippetHit はSearch APIの検索結果から、式で計算したスニペットのみを読み込み、項目ごとに強調された語を取り出す
+// 検索ワードに一致した部分を含まないスニペットは読み込まない
+type snippetHit struct {
+	words map[string][]highlightWord
+}
+
+func (h *snippetHit) Load(fields []search.Field, _ *search.DocumentMetadata) error {
+	for _, f := range fields {
+		if !f.Derived || !strings.HasPrefix(f.Name, snippetPrefix) {
+			continue
+		}
+		var v string
+		switch value := f.Value.(type) {
+		case string:
+			v = value
+		case search.HTML:
+			v = string(value)
+		}
+		field := strings.TrimPrefix(f.Name, snippetPrefix)
+		for _, w := range snippetWords(v) {
+			h.words[field] = append(h.words[field], highlightWord{word: w})
+		}
+	}
+	return nil
+}
+
+func (h *snippetHit) Save() ([]search.Field, *search.DocumentMetadata, error) {
+	return nil, nil, nil
+}
+
+// snippetWords はスニペットでタグに囲まれた語を返す
+func snippetWords(snippet string) []string {
+	var words []string
+	for {
+		i := strings.Index(snippet, highlightStart)
+		if i < 0 {
+			return words
+		}
+		snippet = snippet[i+len(highlightStart):]
+		j := strings.Index(snippet, highlightEnd)
+		if j < 0 {
+			return words
+		}
+		if w := html.UnescapeString(snippet[:j]); w != "" {
+			words = append(words, w)
+		}
+		snippet = snippet[j+len(highlightEnd):]
+	}
+}
+
+// highlight はスニペットで強調された語が出現する範囲を、Datastoreから取得した元の値で強調して検索結果に設定する
+//
+// スニペットはインデックスに登録した正規化・トークナイズ後の文字列から計算されるため、そのままでは元の値と表記が異なることがある。
+// Search APIは大文字と小文字を区別せずに検索するため、強調する範囲も区別せずに探す。
+func (s *SearchAPISearcher) highlight(resp *Response, matched map[int64]map[string][]highlightWord) {
+	n := Normalizer{Fold: true}
+	if s.Normalizer != nil {
+		n = *s.Normalizer
+		n.Fold = true
+	}
+	for i := range resp.Items {
+		r := &resp.Items[i]
+		r.highlight(&n, matched[r.ID])
+	}
+}
+
+// sortOptions は並び順の指定をSearch APIのソートオプションに変換する
+//
+// トークナイズした項目ではトークンを空白区切りで並べた文字列で比較されてしまうため、元の値を登録した *Sort フィールドで並び替える。
+func sortOptions(orders []SortOrder) *search.SortOptions {
+	if len(orders) == 0 {
+		return nil
+	}
+
+	exprs := make([]search.SortExpression, 0, len(orders))
+	for _, o := range orders {
+		// Search APIのソートはデフォルトが降順のため、昇順の場合に Reverse を指定する
+		exprs = append(exprs, search.SortExpression{
+			Expr:    o.Property + "Sort",
+			Reverse: !o.Descending,
+			Default: "",
+		})
+	}
+	return &search.SortOptions{Expressions: exprs}
+}
+
+// hydrate はSearch APIの検索結果のIDをもとに、Datastoreから実データを取得する
+//
+// 検索結果はSearch APIで並び替えた順序のまま返すため、GetMulti の結果をIDと同じ順序で扱い、
+// 実データが存在しないものは順序を変えずに取り除く。
+func (s *SearchAPISearcher) hydrate(ctx context.Context, ids []int64) (*Response, error) {
+	foos, err := s.Repository.GetMulti(ctx, ids)
+	orphans, err := orphanIDs(ids, err)
+	if err != nil {
+		return nil, err
+	}
+	if len(orphans) == 0 {
+		return newResponse(foos), nil
+	}
+
+	// インデックスの削除が反映される前に検索された場合など、削除済みのエンティティを指すドキュメントは
+	// 検索結果から除外し、ドキュメントの削除タスクを登録しておく
+	log.Warningf(ctx, "search hits with no foo; ids: %v", orphans)
+	if err := addIndexTasks(ctx, UnindexTaskPath, orphans); err != nil {
+		log.Errorf(ctx, "failed to create index delete task; error: %#v", err)
+	}
+	found := make([]*Foo, 0, len(foos)-len(orphans))
+	for _, f := range foos {
+		if f != nil {
+			found = append(found, f)
+		}
+	}
+	resp := newResponse(found)
+	resp.Dropped = len(orphans)
+	return resp, nil
+}
+
+// PutMulti はFooを保存し、Search APIのインデックス作成タスクを登録する
+func (s *SearchAPISearcher) PutMulti(ctx context.Context, foos []*Foo) ([]int64, error) {
+	ids, err := s.Repository.PutMulti(ctx, foos)
+	if err != nil {
+		return nil, err
+	}
+
+	// 検索インデックスの作成タスクを行う。
+	// リクエストのレイテンシを下げるために、インデックスの作成はTaskqueueを利用してバックグラウンドで行うようにしている
+	// 更新の場合も同じタスクで最新のエンティティの内容からインデックスを作り直す
+	if err := addIndexTasks(ctx, IndexTaskPath, ids); err != nil {
+		log.Errorf(ctx, "failed to create index create task; error: %#v", err)
+		// トランザクション内ではコミット前のエンティティでインデックスを作成しないよう、エラーを返してロールバックさせる
+		if inTransaction(ctx) {
+			return nil, err
+		}
+		// タスクが登録できなかった場合はインデックスが古いままにならないよう、この場で作成する
+		if err := s.putDocuments(ctx, foos); err != nil {
+			return nil, err
+		}
+	}
+	return ids, nil
+}
+
+// DeleteMulti はFooを削除し、Search APIのインデックス削除タスクを登録する
+func (s *SearchAPISearcher) DeleteMulti(ctx context.Context, ids []int64) error {
+	if err := s.Repository.DeleteMulti(ctx, ids); err != nil {
+		return err
+	}
+
+	if err := addIndexTasks(ctx, UnindexTaskPath, ids); err != nil {
+		log.Errorf(ctx, "failed to create index delete task; error: %#v", err)
+		// トランザクション内ではロールバックされる可能性があるため、ドキュメントは削除せずにエラーを返す
+		if inTransaction(ctx) {
+			return err
+		}
+		// タスクが登録できなかった場合は削除済みのエンティティを指すドキュメントが残らないよう、この場で削除する
+		return s.deleteDocuments(ctx, ids)
+	}
+	return nil
+}
+
+// RegisterTaskHandlers はインデックス更新タスクを受け付けるハンドラをルータに登録する
+func (s *SearchAPISearcher) RegisterTaskHandlers(r *mux.Router) {
+	r.HandleFunc(IndexTaskPath, s.HandleIndex).Methods(http.MethodPost)
+	r.HandleFunc(UnindexTaskPath, s.HandleUnindex).Methods(http.MethodPost)
+}
+
+// HandleIndex は IndexTaskPath へのタスクを受けて、Search APIのインデックスを作成する
+//
+// 対象のエンティティが既に削除されている場合は、インデックスを削除する。
+func (s *SearchAPISearcher) HandleIndex(w http.ResponseWriter, r *http.Request) {
+	ctx := appengine.NewContext(r)
+
+	// リクエストボディからSearch APIインデックス構築対象となるエンティティを取得してくる
+	id, err := taskID(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Search APIインデックス構築対象のエンティティをDatastoreから取得する
+	foo, err := s.Repository.Get(ctx, id)
+	if err == datastore.ErrNoSuchEntity {
+		// タスクの実行までの間に削除されたエンティティのインデックスは作成せずに削除しておく
+		log.Infof(ctx, "foo is already deleted; id: %v", id)
+		if err := s.deleteDocuments(ctx, []int64{id}); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	} else if err != nil {
+		log.Errorf(ctx, "failed to get foo; id: %v, error: %#v", id, err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err := s.putDocuments(ctx, []*Foo{foo}); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// HandleUnindex は UnindexTaskPath へのタスクを受けて、Search APIのインデックスを削除する
+func (s *SearchAPISearcher) HandleUnindex(w http.ResponseWriter, r *http.Request) {
+	ctx := appengine.NewContext(r)
+
+	id, err := taskID(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := s.deleteDocuments(ctx, []int64{id}); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// putDocuments はFooの内容からSearch APIのドキュメントを作成する
+func (s *SearchAPISearcher) putDocuments(ctx context.Context, foos []*Foo) error {
+	// Search APIインデックス構築処理
+	index, err := search.Open(s.Index)
+	if err != nil {
+		log.Errorf(ctx, "failed to open index %v : %#v", s.Index, err)
+		return err
+	}
+	for _, foo := range foos {
+		local, domain := emailTerms(s.Normalizer.Normalize(foo.Email))
+		fooIdx := &fooIndex{
+			FamilyName: s.tokenize(foo.FamilyName),
+			GivenName:  s.tokenize(foo.GivenName),
+			Email:      s.tokenize(foo.Email),
+			// 読みとローマ字表記でも検索できるよう、それぞれをトークナイズして登録する
+			FamilyNameKana: s.tokenizeValues(readingValues(foo.FamilyNameKana)),
+			GivenNameKana:  s.tokenizeValues(readingValues(foo.GivenNameKana)),
+			Local:          local,
+			Domain:         domain,
+			FamilyNameSort: search.Atom(foo.FamilyName),
+			GivenNameSort:  search.Atom(foo.GivenName),
+			EmailSort:      search.Atom(foo.Email),
+		}
+		// ファセットは集計に使用する値をそのまま登録する
+		for _, name := range []string{"domain", "familyName"} {
+			if v := facetValues[name](foo); v != "" {
+				fooIdx.Facets = append(fooIdx.Facets, search.Facet{Name: name, Value: search.Atom(v)})
+			}
+		}
+		if s.Ranking != nil {
+			fooIdx.Boost = s.Ranking.boost(fooIdx)
+			fooIdx.FamilyNameExact = s.Ranking.exactFamilyName(strings.TrimSpace(s.Normalizer.Normalize(foo.FamilyName)))
+		}
+		// Datastoreと紐付けるために、Search APIのインデックスのIDでとして、DatastoreのエンティティのIDを指定している
+		if _, err := index.Put(ctx, strconv.FormatInt(foo.ID, 10), fooIdx); err != nil {
+			log.Errorf(ctx, "failed to put index : %#v", err)
+			return err
+		}
+	}
+	return nil
+}
+
+// deleteDocuments は指定したIDのSearch APIのドキュメントを削除する
+func (s *SearchAPISearcher) deleteDocuments(ctx context.Context, ids []int64) error {
+	index, err := search.Open(s.Index)
+	if err != nil {
+		log.Errorf(ctx, "failed to open index %v : %#v", s.Index, err)
+		return err
+	}
+	for _, id := range ids {
+		if err := index.Delete(ctx, strconv.FormatInt(id, 10)); err != nil {
+			log.Errorf(ctx, "failed to delete index; id: %v, error: %#v", id, err)
+			return err
+		}
+	}
+	return nil
+}
+
+// maxTasksPerAdd は一度に登録できるタスクの件数の上限
+const maxTasksPerAdd = 100
+
+// addIndexTasks は指定したIDごとにインデックス更新タスクを登録する
+// 一度に登録できる件数を超える場合は、分割して登録する
+func addIndexTasks(ctx context.Context, path string, ids []int64) error {
+	tasks := make([]*taskqueue.Task, 0, len(ids))
+	for _, id := range ids {
+		val := url.Values{"id": {strconv.FormatInt(id, 10)}}
+		t := taskqueue.NewPOSTTask(path, val)
+		tasks = append(tasks, t)
+	}
+	for len(tasks) > 0 {
+		n := minInt(len(tasks), maxTasksPerAdd)
+		if _, err := taskqueue.AddMulti(ctx, tasks[:n], "default"); err != nil {
+			return err
+		}
+		tasks = tasks[n:]
+	}
+	return nil
+}
+
+// taskID はタスクのリクエストから対象のFooのIDを取得する
+func taskID(r *http.Request) (int64, error) {
+	return strconv.ParseInt(r.FormValue("id"), 10, 64)
+}
+
+// tokenize は正規化した文字列を Tokenizer でトークナイズし、空白区切りで列挙する
+func (s *SearchAPISearcher) tokenize(str string) string {
+	return strings.Join(s.Tokenizer.Tokenize(s.Normalizer.Normalize(str)), " ")
+}
+
+// tokenizeValues は複数の文字列をそれぞれトークナイズし、空白区切りで列挙する
+func (s *SearchAPISearcher) tokenizeValues(values []string) string {
+	tokens := make([]string, 0, len(values))
+	for _, v := range values {
+		tokens = append(tokens, s.tokenize(v))
+	}
+	return strings.Join(tokens, " ")
+}
+
+// normalizeQuery はSearch APIの検索クエリを正規化する
+func (s *SearchAPISearcher) normalizeQuery(query string) string {
+	if s.Normalizer == nil {
+		return query
+	}
+	// Search APIは大文字と小文字を区別せずに検索するため、
+	// AND や OR などの演算子を変換してしまわないよう、検索クエリでは大文字小文字の変換は行わない
+	n := *s.Normalizer
+	n.Fold = false
+	return n.Normalize(query)
+}
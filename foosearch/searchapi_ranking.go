@@ -0,0 +1,213 @@
+package foosearch
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"strings"
+	"unicode"
+
+	"google.golang.org/appengine/search"
+)
+
+// DefaultSortLimit はSearch APIでスコアにより並び替える件数の上限
+const DefaultSortLimit = 1000
+
+// SearchAPIRanking はSearch APIの検索結果の順位付けの設定
+//
+// Search APIの MatchScorer は検索ワードの出現回数をもとにスコアを計算し、項目ごとの重みを指定できない。
+// そのため、重みの分だけ項目のトークンを繰り返した Boost フィールドをドキュメントに登録し、
+// 重みの大きい項目に一致したドキュメントほど出現回数が多くなるようにしている。
+// 重みはインデックスの作成時に反映されるため、変更した場合は登録済みのFooのインデックスを作り直す必要がある。
+type SearchAPIRanking struct {
+	// Expr は並び替えに使用する式。MatchScorer のスコアは _score で参照できる
+	Expr string `json:"expr"`
+	// Weights は項目ごとの重み。キーは FamilyName、GivenName、Email で、読みは姓・名と同じ重みとする
+	// 1以下の項目は Boost フィールドに登録しない
+	Weights map[string]int `json:"weights"`
+	// ExactFamilyName は姓が検索ワードと完全に一致した場合の重み。0の場合は完全一致を優先しない
+	ExactFamilyName int `json:"exactFamilyName"`
+	// Limit はスコアで並び替える件数の上限。これを超える検索結果は並び替えずに返される
+	Limit int `json:"limit"`
+}
+
+// NewSearchAPIRanking は姓、名、メールアドレスの順に重みをつけ、姓の完全一致を優先する SearchAPIRanking を返す
+func NewSearchAPIRanking() *SearchAPIRanking {
+	return &SearchAPIRanking{
+		Expr: "_score",
+		Weights: map[string]int{
+			"FamilyName": 3,
+			"GivenName":  2,
+			"Email":      1,
+		},
+		ExactFamilyName: 3,
+		Limit:           DefaultSortLimit,
+	}
+}
+
+// LoadSearchAPIRanking はJSONファイルから SearchAPIRanking を読み込む
+// ファイルで指定されなかった項目は NewSearchAPIRanking の値とする
+func LoadSearchAPIRanking(path string) (*SearchAPIRanking, error) {
+	body, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	r := NewSearchAPIRanking()
+	if err := json.Unmarshal(body, r); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// sortOptions はスコアの高い順に並び替えるソートオプションを返す
+func (r *SearchAPIRanking) sortOptions() *search.SortOptions {
+	limit := r.Limit
+	if limit <= 0 {
+		limit = DefaultSortLimit
+	}
+	return &search.SortOptions{
+		// Search APIのソートはデフォルトが降順のため、Reverse は指定しない
+		Expressions: []search.SortExpression{{Expr: r.Expr, Default: 0.0}},
+		Scorer:      search.MatchScorer,
+		Limit:       limit,
+	}
+}
+
+// weight は項目の重みを返す
+func (r *SearchAPIRanking) weight(field string) int {
+	if r == nil {
+		return 0
+	}
+	return r.Weights[field]
+}
+
+// boost は項目ごとのトークンを、重みから1を引いた回数だけ繰り返して空白区切りで列挙する
+// 項目自体のフィールドとあわせて、重みの回数だけ出現することになる
+func (r *SearchAPIRanking) boost(x *fooIndex) string {
+	var tokens []string
+	for _, f := range []struct {
+		weight int
+		values []string
+	}{
+		{r.weight("FamilyName"), []string{x.FamilyName, x.FamilyNameKana}},
+		{r.weight("GivenName"), []string{x.GivenName, x.GivenNameKana}},
+		{r.weight("Email"), []string{x.Email}},
+	} {
+		for i := 1; i < f.weight; i++ {
+			for _, v := range f.values {
+				if v != "" {
+					tokens = append(tokens, v)
+				}
+			}
+		}
+	}
+	return strings.Join(tokens, " ")
+}
+
+// exactFamilyName は姓の完全一致を優先するためのAtomフィールドの値を、重みの回数だけ返す
+func (r *SearchAPIRanking) exactFamilyName(familyName string) []string {
+	if r == nil || familyName == "" {
+		return nil
+	}
+	values := make([]string, 0, r.ExactFamilyName)
+	for i := 0; i < r.ExactFamilyName; i++ {
+		values = append(values, familyName)
+	}
+	return values
+}
+
+// query は姓が検索ワードと完全に一致するドキュメントのスコアが高くなるよう、検索クエリに条件を追加する
+//
+// words は項目を指定していない検索ワードで、いずれかと姓が完全に一致する場合に条件を満たす。
+// 追加する条件は元の検索クエリとのAND条件とするため、検索結果は変わらない。
+func (r *SearchAPIRanking) query(query string, words []string) string {
+	if r == nil || r.ExactFamilyName <= 0 || query == "" {
+		return query
+	}
+	var exact []string
+	for _, w := range words {
+		if w = strings.Replace(w, `"`, "", -1); w != "" {
+			exact = append(exact, `FamilyNameExact:"`+w+`"`)
+		}
+	}
+	if len(exact) == 0 {
+		return query
+	}
+	return "(" + query + ") OR ((" + query + ") AND (" + strings.Join(exact, " OR ") + "))"
+}
+
+// freeTextWords はSearch APIの検索クエリから、項目を指定しておらず否定もされていない語を返す
+// 引用符で囲まれたフレーズは1つの語とする
+func freeTextWords(query string) []string {
+	var (
+		words  []string
+		tokens = searchQueryTokens(query)
+	)
+	for i := 0; i < len(tokens); i++ {
+		t := tokens[i]
+		switch {
+		case t == "AND" || t == "OR" || t == "(" || t == ")":
+		case t == "NOT":
+			i = skipOperand(tokens, i+1)
+		case strings.HasPrefix(t, `"`):
+			words = append(words, strings.TrimSpace(strings.Trim(t, `"`)))
+		case strings.ContainsAny(t, ":=<>"):
+			// "familyName:" の直後の語やフレーズ、括弧も項目を指定した語とする
+			if strings.ContainsAny(t[len(t)-1:], ":=<>") {
+				i = skipOperand(tokens, i+1)
+			}
+		default:
+			words = append(words, t)
+		}
+	}
+	return words
+}
+
+// searchQueryTokens はSearch APIの検索クエリを、語、引用符で囲まれたフレーズ、括弧に分割する
+func searchQueryTokens(query string) []string {
+	var (
+		tokens []string
+		rs     = []rune(query)
+	)
+	for i := 0; i < len(rs); {
+		j := i + 1
+		switch r := rs[i]; {
+		case unicode.IsSpace(r):
+			i = j
+			continue
+		case r == '"':
+			for j < len(rs) && rs[j] != '"' {
+				j++
+			}
+			if j < len(rs) {
+				j++
+			}
+		case r != '(' && r != ')':
+			for j < len(rs) && !unicode.IsSpace(rs[j]) && !strings.ContainsRune(`()"`, rs[j]) {
+				j++
+			}
+		}
+		tokens = append(tokens, string(rs[i:j]))
+		i = j
+	}
+	return tokens
+}
+
+// skipOperand は i 番目から始まる語または括弧で囲まれた範囲の最後の位置を返す
+func skipOperand(tokens []string, i int) int {
+	if i >= len(tokens) || tokens[i] != "(" {
+		return i
+	}
+	depth := 0
+	for ; i < len(tokens); i++ {
+		switch tokens[i] {
+		case "(":
+			depth++
+		case ")":
+			depth--
+		}
+		if depth == 0 {
+			return i
+		}
+	}
+	return i
+}
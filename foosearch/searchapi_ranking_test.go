@@ -0,0 +1,53 @@
+package foosearch
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFreeTextWords(t *testing.T) {
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"鈴木", []string{"鈴木"}},
+		{"鈴木 一郎", []string{"鈴木", "一郎"}},
+		{"鈴木 OR (山田 AND 花子)", []string{"鈴木", "山田", "花子"}},
+		{`"鈴木 一郎"`, []string{"鈴木 一郎"}},
+		{"FamilyName:鈴木 一郎", []string{"一郎"}},
+		{`GivenName:"一郎" domain:(sample.com OR example.com) 鈴木`, []string{"鈴木"}},
+		{"NOT 鈴木 一郎", []string{"一郎"}},
+		{"NOT (鈴木 OR 山田) 一郎", []string{"一郎"}},
+		{"FamilyName:鈴木", nil},
+	}
+	for _, tt := range tests {
+		if got := freeTextWords(tt.query); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("freeTextWords(%q) = %q, want %q", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestSearchAPIRankingQuery(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"鈴木", `(鈴木) OR ((鈴木) AND (FamilyNameExact:"鈴木"))`},
+		// 元のクエリとのAND条件とするため、姓のみが一致するドキュメントは検索結果に含まれない
+		{"鈴木 一郎", `(鈴木 一郎) OR ((鈴木 一郎) AND (FamilyNameExact:"鈴木" OR FamilyNameExact:"一郎"))`},
+		// 項目を指定した語は姓の完全一致の条件としない
+		{"GivenName:一郎 鈴木", `(GivenName:一郎 鈴木) OR ((GivenName:一郎 鈴木) AND (FamilyNameExact:"鈴木"))`},
+		{"FamilyName:鈴木", "FamilyName:鈴木"},
+	}
+	r := NewSearchAPIRanking()
+	for _, tt := range tests {
+		if got := r.query(tt.query, freeTextWords(tt.query)); got != tt.want {
+			t.Errorf("query(%q) = %s, want %s", tt.query, got, tt.want)
+		}
+	}
+
+	r.ExactFamilyName = 0
+	if got := r.query("鈴木", []string{"鈴木"}); got != "鈴木" {
+		t.Errorf("query without ExactFamilyName = %s, want 鈴木", got)
+	}
+}
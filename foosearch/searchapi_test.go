@@ -0,0 +1,87 @@
+package foosearch
+
+import (
+	"reflect"
+	"testing"
+
+	"google.golang.org/appengine/search"
+)
+
+func TestSortOptions(t *testing.T) {
+	opts := sortOptions([]SortOrder{{Property: "FamilyName"}, {Property: "Email", Descending: true}})
+	want := []search.SortExpression{
+		{Expr: "FamilyNameSort", Reverse: true, Default: ""},
+		{Expr: "EmailSort", Reverse: false, Default: ""},
+	}
+	if !reflect.DeepEqual(opts.Expressions, want) {
+		t.Errorf("got %+v, want %+v", opts.Expressions, want)
+	}
+	if opts := sortOptions(nil); opts != nil {
+		t.Errorf("got %+v, want nil", opts)
+	}
+}
+
+func TestFooIndexSortFields(t *testing.T) {
+	x := &fooIndex{
+		FamilyName:     "山田",
+		FamilyNameSort: "山田",
+		GivenNameSort:  "太郎",
+		EmailSort:      "T-Yamada@sample.com",
+	}
+	fields, meta, err := x.Save()
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded := new(fooIndex)
+	if err := loaded.Load(fields, meta); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(loaded, x) {
+		t.Errorf("got %+v, want %+v", loaded, x)
+	}
+}
+
+func TestSnippetWords(t *testing.T) {
+	tests := []struct {
+		snippet string
+		want    []string
+	}{
+		{"<b>鈴木</b>", []string{"鈴木"}},
+		{"鈴 <b>鈴木</b> 一 <b>一郎</b>", []string{"鈴木", "一郎"}},
+		{"<b>t&amp;yamada</b>@sample.com", []string{"t&yamada"}},
+		{"鈴木", nil},
+	}
+	for _, tt := range tests {
+		if got := snippetWords(tt.snippet); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("snippetWords(%q) = %q, want %q", tt.snippet, got, tt.want)
+		}
+	}
+}
+
+// スニペットで強調された語は、正規化・トークナイズ前の元の値で強調する
+func TestSearchAPISearcherHighlight(t *testing.T) {
+	s := NewForwardMatchSearchAPISearcher(NewMemoryRepository(), "foo")
+	s.Normalizer = &Normalizer{Fold: true, Kana: true}
+	resp := newResponse([]*Foo{
+		{ID: 1, FamilyName: "ヤマダ", GivenName: "花子", Email: "H-Yamada@example.com"},
+		{ID: 2, FamilyName: "山田", GivenName: "太郎", Email: "t-yamada@sample.com"},
+	})
+	hit := &snippetHit{words: make(map[string][]highlightWord)}
+	err := hit.Load([]search.Field{
+		{Name: "snippetFamilyName", Value: search.HTML("や <b>やま</b> やまだ"), Derived: true},
+		{Name: "snippetEmail", Value: search.HTML("h h- <b>h-yamada</b>"), Derived: true},
+		{Name: "snippetGivenName", Value: search.HTML("花 花子"), Derived: true},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.highlight(resp, map[int64]map[string][]highlightWord{1: hit.words})
+
+	want := map[string]string{"FamilyName": "<b>ヤマ</b>ダ", "Email": "<b>H-Yamada</b>@example.com"}
+	if got := resp.Items[0].Highlights; !reflect.DeepEqual(got, want) {
+		t.Errorf("highlights = %v, want %v", got, want)
+	}
+	if got := resp.Items[1].Highlights; got != nil {
+		t.Errorf("highlights of a foo without snippets = %v, want nil", got)
+	}
+}
@@ -0,0 +1,289 @@
+package foosearch
+
+import (
+	"reflect"
+	"testing"
+
+	"golang.org/x/net/context"
+)
+
+var testFoos = []Foo{
+	{FamilyName: "田中", GivenName: "太郎", Email: "tanaka@sample.com", FamilyNameKana: "たなか", GivenNameKana: "たろう"},
+	{FamilyName: "田所", GivenName: "三郎", Email: "tadokoro@sample.com"},
+	{FamilyName: "鈴木", GivenName: "一郎", Email: "i-suzuki@sample.com"},
+	{FamilyName: "鈴木", GivenName: "次郎", Email: "j-suzuki@example.com"},
+	{FamilyName: "一郎", GivenName: "鈴木", Email: "i-suzuki2@sample.com"},
+	{FamilyName: "山田", GivenName: "花子", Email: "h-yamada@example.com"},
+	{FamilyName: "山田", GivenName: "太郎", Email: "t-yamada@sample.com"},
+	{FamilyName: "メロン", GivenName: "太郎", Email: "meron@sample.com"},
+	{FamilyName: "ロンメロ", GivenName: "太郎", Email: "ronmero@sample.com"},
+}
+
+// putFoos は testFoos を登録する
+func putFoos(t *testing.T, s Store) {
+	foos := make([]*Foo, len(testFoos))
+	for i := range testFoos {
+		f := testFoos[i]
+		foos[i] = &f
+	}
+	if _, err := s.PutMulti(context.Background(), foos); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// fullNames は検索結果の姓と名をつなげた一覧を返す
+func fullNames(items []Result) []string {
+	var names []string
+	for _, r := range items {
+		names = append(names, r.FamilyName+r.GivenName)
+	}
+	return names
+}
+
+func TestSearchers(t *testing.T) {
+	tests := []struct {
+		name     string
+		strategy func(Repository) Strategy
+		query    Query
+		want     []string
+	}{
+		{
+			name:     "equal",
+			strategy: func(r Repository) Strategy { return NewEqualSearcher(r) },
+			query:    Query{FamilyName: "鈴木"},
+			want:     []string{"鈴木一郎", "鈴木次郎"},
+		},
+		{
+			name:     "equal with sort",
+			strategy: func(r Repository) Strategy { return NewEqualSearcher(r) },
+			query:    Query{FamilyName: "鈴木", Sort: []SortOrder{{Property: "GivenName", Descending: true}}},
+			want:     []string{"鈴木次郎", "鈴木一郎"},
+		},
+		{
+			name:     "forward match",
+			strategy: func(r Repository) Strategy { return NewForwardMatchSearcher(r) },
+			query:    Query{FamilyName: "田"},
+			want:     []string{"田中太郎", "田所三郎"},
+		},
+		{
+			name:     "forward match with multiple conditions",
+			strategy: func(r Repository) Strategy { return NewForwardMatchSearcher(r) },
+			query:    Query{FamilyName: "山", GivenName: "花"},
+			want:     []string{"山田花子"},
+		},
+		{
+			name:     "prefix token",
+			strategy: func(r Repository) Strategy { return NewPrefixTokenSearcher(r) },
+			query:    Query{FamilyName: "鈴"},
+			want:     []string{"鈴木一郎", "鈴木次郎"},
+		},
+		{
+			name:     "or",
+			strategy: func(r Repository) Strategy { return NewOrSearcher(r) },
+			query:    Query{FamilyName: "鈴木", GivenName: "花子"},
+			want:     []string{"鈴木一郎", "鈴木次郎", "山田花子"},
+		},
+		{
+			name:     "ngram",
+			strategy: func(r Repository) Strategy { return NewNGramSearcher(r) },
+			query:    Query{Text: "ロン"},
+			want:     []string{"メロン太郎", "ロンメロ太郎"},
+		},
+		{
+			name:     "ngram with sort",
+			strategy: func(r Repository) Strategy { return NewNGramSearcher(r) },
+			query:    Query{Text: "太郎", Sort: []SortOrder{{Property: "Email"}}},
+			want:     []string{"メロン太郎", "ロンメロ太郎", "山田太郎", "田中太郎"},
+		},
+		{
+			name:     "ngram with a single character",
+			strategy: func(r Repository) Strategy { return NewNGramSearcher(r) },
+			query:    Query{Text: "花"},
+			want:     []string{"山田花子"},
+		},
+		{
+			name:     "ngram by reading",
+			strategy: func(r Repository) Strategy { return NewNGramSearcher(r) },
+			query:    Query{FamilyName: "tanaka"},
+			want:     []string{"田中太郎"},
+		},
+		{
+			name:     "ngram by email local part",
+			strategy: func(r Repository) Strategy { return NewNGramSearcher(r) },
+			query:    Query{Text: "local:suzuki"},
+			want:     []string{"鈴木一郎", "鈴木次郎"},
+		},
+		{
+			name:     "ngram by email domain",
+			strategy: func(r Repository) Strategy { return NewNGramSearcher(r) },
+			query:    Query{Text: "domain:example.com"},
+			want:     []string{"鈴木次郎", "山田花子"},
+		},
+		{
+			name:     "query language",
+			strategy: func(r Repository) Strategy { return NewQuerySearcher(r) },
+			query:    Query{Text: "familyName:鈴木 OR givenName:花子"},
+			want:     []string{"鈴木一郎", "鈴木次郎", "山田花子"},
+		},
+		{
+			name:     "query language with prefix and negation",
+			strategy: func(r Repository) Strategy { return NewQuerySearcher(r) },
+			query:    Query{Text: "givenName:太* NOT 田中"},
+			want:     []string{"山田太郎", "メロン太郎", "ロンメロ太郎"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := tt.strategy(NewMemoryRepository())
+			putFoos(t, s)
+
+			resp, err := s.Search(context.Background(), tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := fullNames(resp.Items); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Search(%+v) = %v, want %v", tt.query, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSearchersPaging(t *testing.T) {
+	tests := []struct {
+		name     string
+		strategy func(Repository) Strategy
+		query    Query
+		want     []string
+	}{
+		{
+			name:     "equal",
+			strategy: func(r Repository) Strategy { return NewEqualSearcher(r) },
+			query:    Query{Email: "meron@sample.com"},
+			want:     []string{"メロン太郎"},
+		},
+		{
+			name:     "forward match",
+			strategy: func(r Repository) Strategy { return NewForwardMatchSearcher(r) },
+			query:    Query{FamilyName: "田"},
+			want:     []string{"田中太郎", "田所三郎"},
+		},
+		{
+			name:     "forward match with multiple conditions",
+			strategy: func(r Repository) Strategy { return NewForwardMatchSearcher(r) },
+			query:    Query{FamilyName: "山", GivenName: "太"},
+			want:     []string{"山田太郎"},
+		},
+		{
+			name:     "or",
+			strategy: func(r Repository) Strategy { return NewOrSearcher(r) },
+			query:    Query{FamilyName: "鈴木", GivenName: "太郎"},
+			want:     []string{"田中太郎", "鈴木一郎", "鈴木次郎", "山田太郎", "メロン太郎", "ロンメロ太郎"},
+		},
+		{
+			name:     "ngram",
+			strategy: func(r Repository) Strategy { return NewNGramSearcher(r) },
+			query:    Query{Text: "太郎"},
+			want:     []string{"田中太郎", "山田太郎", "メロン太郎", "ロンメロ太郎"},
+		},
+		{
+			name:     "query language",
+			strategy: func(r Repository) Strategy { return NewQuerySearcher(r) },
+			query:    Query{Text: "familyName:鈴* OR givenName:太郎"},
+			want:     []string{"田中太郎", "鈴木一郎", "鈴木次郎", "山田太郎", "メロン太郎", "ロンメロ太郎"},
+		},
+		{
+			name:     "query language with several prefixes",
+			strategy: func(r Repository) Strategy { return NewQuerySearcher(r) },
+			query:    Query{Text: "familyName:田* email:ta*"},
+			want:     []string{"田中太郎", "田所三郎"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := tt.strategy(NewMemoryRepository())
+			putFoos(t, s)
+
+			q := tt.query
+			q.Limit = 2
+			var got []string
+			for i := 0; ; i++ {
+				if i > len(testFoos) {
+					t.Fatal("paging does not end")
+				}
+				resp, err := s.Search(context.Background(), q)
+				if err != nil {
+					t.Fatal(err)
+				}
+				got = append(got, fullNames(resp.Items)...)
+				if !resp.HasMore {
+					break
+				}
+				q.Cursor = resp.NextCursor
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("paged Search(%+v) = %v, want %v", tt.query, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSearchersInvalidQuery(t *testing.T) {
+	tests := []struct {
+		name     string
+		strategy func(Repository) Strategy
+		query    Query
+	}{
+		{"invalid cursor", func(r Repository) Strategy { return NewEqualSearcher(r) }, Query{Cursor: "!"}},
+		{"equal fuzzy", func(r Repository) Strategy { return NewEqualSearcher(r) }, Query{FamilyName: "鈴木", Fuzzy: 1}},
+		{"forward match fuzzy", func(r Repository) Strategy { return NewForwardMatchSearcher(r) }, Query{FamilyName: "鈴", Fuzzy: 1}},
+		{"prefix token fuzzy", func(r Repository) Strategy { return NewPrefixTokenSearcher(r) }, Query{FamilyName: "鈴", Fuzzy: 1}},
+		{"or fuzzy", func(r Repository) Strategy { return NewOrSearcher(r) }, Query{FamilyName: "鈴木", Fuzzy: 1}},
+		{"query language fuzzy", func(r Repository) Strategy { return NewQuerySearcher(r) }, Query{Text: "鈴木", Fuzzy: 1}},
+		{"query language syntax", func(r Repository) Strategy { return NewQuerySearcher(r) }, Query{Text: "(鈴木"}},
+		{"query language sort", func(r Repository) Strategy { return NewQuerySearcher(r) }, Query{Text: "鈴木", Sort: []SortOrder{{Property: "FamilyName"}}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := tt.strategy(NewMemoryRepository())
+			putFoos(t, s)
+
+			_, err := s.Search(context.Background(), tt.query)
+			if _, ok := err.(*InvalidQueryError); !ok {
+				t.Errorf("Search(%+v) error = %v, want *InvalidQueryError", tt.query, err)
+			}
+		})
+	}
+}
+
+func TestNGramSearcherRejected(t *testing.T) {
+	s := NewNGramSearcher(NewMemoryRepository())
+	putFoos(t, s)
+
+	// 「ロンメロ」はトークンの「メロ」と「ロン」を含むが、「メロン」は含まない
+	resp, err := s.Search(context.Background(), Query{Text: "メロン"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := fullNames(resp.Items), []string{"メロン太郎"}; !reflect.DeepEqual(got, want) || resp.Rejected != 1 {
+		t.Errorf("Search() = %v, Rejected = %d, want %v, 1", got, resp.Rejected, want)
+	}
+}
+
+func TestNGramSearcherSelectTokens(t *testing.T) {
+	s := NewNGramSearcher(NewMemoryRepository())
+	s.Stats = NewMemoryTokenStats()
+	s.MaxFilters = 1
+	putFoos(t, s)
+
+	if got, want := s.selectTokens(context.Background(), []string{"* 太郎", "* 山田"}), []string{"* 山田"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("selectTokens() = %q, want %q", got, want)
+	}
+	// クエリに指定しなかったトークンは候補の検証で確認する
+	resp, err := s.Search(context.Background(), Query{Text: "t-yamada"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := fullNames(resp.Items), []string{"山田太郎"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Search() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,113 @@
+package foosearch
+
+import (
+	"sort"
+	"strings"
+)
+
+// SortOrder は検索結果の並び順の指定
+type SortOrder struct {
+	// Property は並び替えに使用するプロパティ名
+	Property   string
+	Descending bool
+}
+
+// sortParams は並び順に指定できるパラメータ名とプロパティの対応
+var sortParams = map[string]string{
+	"familyName": "FamilyName",
+	"givenName":  "GivenName",
+	"email":      "Email",
+}
+
+// ParseSort は `familyName,-email` 形式の文字列を並び順の指定に変換する
+//
+// 先頭に `-` をつけた項目は降順となる。
+func ParseSort(s string) ([]SortOrder, error) {
+	if s == "" {
+		return nil, nil
+	}
+
+	var (
+		orders []SortOrder
+		seen   = make(map[string]bool)
+	)
+	for _, param := range strings.Split(s, ",") {
+		param = strings.TrimSpace(param)
+		desc := strings.HasPrefix(param, "-")
+		if desc {
+			param = param[1:]
+		}
+		property, ok := sortParams[param]
+		if !ok {
+			return nil, &InvalidQueryError{Reason: "unknown sort field: " + param}
+		}
+		if seen[property] {
+			return nil, &InvalidQueryError{Reason: "duplicated sort field: " + param}
+		}
+		seen[property] = true
+		orders = append(orders, SortOrder{Property: property, Descending: desc})
+	}
+	return orders, nil
+}
+
+// String は datastore.Query.Order と同じ形式の文字列を返す
+func (o SortOrder) String() string {
+	if o.Descending {
+		return "-" + o.Property
+	}
+	return o.Property
+}
+
+// withOrders はクエリに並び順を設定する
+//
+// 並び順が指定されている場合は、同じ値のFooの順序が一定になるようにキーの順を最後に加える。
+func withOrders(q *EntityQuery, orders []SortOrder) *EntityQuery {
+	if len(orders) == 0 {
+		return q
+	}
+	for _, o := range orders {
+		q = q.Order(o.String())
+	}
+	return q.Order("__key__")
+}
+
+// compareFoos は並び順の指定に従ってFooを比較する。同じ順位の場合はキーの順とする
+func compareFoos(a, b *Foo, orders []SortOrder) int {
+	for _, o := range orders {
+		av, _ := propertyValues(a, o.Property)
+		bv, _ := propertyValues(b, o.Property)
+		c := strings.Compare(firstValue(av), firstValue(bv))
+		if o.Descending {
+			c = -c
+		}
+		if c != 0 {
+			return c
+		}
+	}
+	switch {
+	case a.ID < b.ID:
+		return -1
+	case a.ID > b.ID:
+		return 1
+	}
+	return 0
+}
+
+// sortResults は並び順の指定に従って並び替えた検索結果を返す。並び順の指定がない場合はキーの順とする
+func sortResults(foos []*Foo, orders []SortOrder) []Result {
+	results := make([]Result, len(foos))
+	for i, f := range foos {
+		results[i] = Result{Foo: f}
+	}
+	sort.Slice(results, func(i, j int) bool {
+		return compareFoos(results[i].Foo, results[j].Foo, orders) < 0
+	})
+	return results
+}
+
+func firstValue(values []string) string {
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
@@ -0,0 +1,72 @@
+package foosearch
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// Tokenizer は検索インデックスに登録するトークンと、検索ワードのトークンを生成する
+//
+// 検索ワードのトークンがすべてインデックスのトークンに含まれている場合に、検索ワードに一致するものとする。
+type Tokenizer interface {
+	// Tokenize はインデックスに登録するトークンを生成する
+	Tokenize(s string) []string
+	// QueryTokens は検索ワードのトークンを生成する
+	QueryTokens(s string) []string
+}
+
+// NGramTokenizer は文字列をNGramでトークナイズする
+//
+// NGramに加えてUnigramもインデックスに登録しておき、NGramの文字数に満たない検索ワードはUnigramで検索する。
+type NGramTokenizer struct {
+	// N はNGramの文字数。0の場合は DefaultNGramSize を使用する
+	N int
+}
+
+// Tokenize はNGramとUnigramのトークンを生成する
+func (t NGramTokenizer) Tokenize(s string) []string {
+	n := ngramSize(t.N)
+	tokens := nGram(s, n)
+	if n == 1 {
+		return tokens
+	}
+	return append(tokens, nGram(s, 1)...)
+}
+
+// QueryTokens はNGramのトークンを生成する。検索ワードがNGramの文字数に満たない場合はUnigramのトークンを生成する
+func (t NGramTokenizer) QueryTokens(s string) []string {
+	n := ngramSize(t.N)
+	if utf8.RuneCountInString(s) < n {
+		return nGram(s, 1)
+	}
+	return nGram(s, n)
+}
+
+// PrefixTokenizer は文字列の前方一致となるトークンを生成する
+type PrefixTokenizer struct{}
+
+// Tokenize は文字列の前方一致となるトークンを列挙する
+func (PrefixTokenizer) Tokenize(s string) []string {
+	return prefixes(s)
+}
+
+// QueryTokens は検索ワードそのものをトークンとする
+func (PrefixTokenizer) QueryTokens(s string) []string {
+	if s == "" {
+		return []string{}
+	}
+	return []string{s}
+}
+
+// WhitespaceTokenizer は文字列を空白で区切ってトークンとする
+type WhitespaceTokenizer struct{}
+
+// Tokenize は空白で区切った文字列を返す
+func (WhitespaceTokenizer) Tokenize(s string) []string {
+	return strings.Fields(s)
+}
+
+// QueryTokens は空白で区切った文字列を返す
+func (WhitespaceTokenizer) QueryTokens(s string) []string {
+	return strings.Fields(s)
+}
@@ -0,0 +1,180 @@
+package foosearch
+
+import (
+	"sync"
+
+	"golang.org/x/net/context"
+	"google.golang.org/appengine"
+	"google.golang.org/appengine/datastore"
+)
+
+// TokenStats はトークンごとの出現件数(トークンを含むFooの件数)を保持する
+//
+// 検索時にトークンの絞り込み効果を見積もるためのもので、件数は厳密でなくてもよい。
+type TokenStats interface {
+	// Add はトークンごとの件数に差分を加える
+	Add(ctx context.Context, deltas map[string]int) error
+	// Counts はトークンごとの件数を返す。記録のないトークンは0件とする
+	Counts(ctx context.Context, tokens []string) (map[string]int, error)
+}
+
+// tokenStatsDeltas は更新前後のトークンから、件数の差分を計算する
+// 1件のFooに同じトークンが複数含まれていても1件として数える
+func tokenStatsDeltas(deltas map[string]int, before, after []string) {
+	seen := make(map[string]bool, len(before))
+	for _, t := range before {
+		if !seen[t] {
+			seen[t] = true
+			deltas[t]--
+		}
+	}
+	seen = make(map[string]bool, len(after))
+	for _, t := range after {
+		if !seen[t] {
+			seen[t] = true
+			deltas[t]++
+		}
+	}
+	for t, d := range deltas {
+		if d == 0 {
+			delete(deltas, t)
+		}
+	}
+}
+
+// DatastoreTokenStats はDatastoreにトークンごとの件数を保存する TokenStats
+//
+// トークンをキー名としたエンティティに件数を保存する。
+// 更新はトランザクションを使用せずに行うため、同時に更新された場合は件数がずれることがある。
+type DatastoreTokenStats struct {
+	Kind string
+}
+
+// NewDatastoreTokenStats は指定したKindに件数を保存する DatastoreTokenStats を返す
+func NewDatastoreTokenStats(kind string) *DatastoreTokenStats {
+	return &DatastoreTokenStats{Kind: kind}
+}
+
+type tokenStat struct {
+	Count int `datastore:",noindex"`
+}
+
+// datastoreBatchSize は1回のGetMulti、PutMultiで扱うキーの件数
+const datastoreBatchSize = 500
+
+// Add はトークンごとの件数に差分を加える
+func (s *DatastoreTokenStats) Add(ctx context.Context, deltas map[string]int) error {
+	tokens := make([]string, 0, len(deltas))
+	for t := range deltas {
+		tokens = append(tokens, t)
+	}
+
+	for len(tokens) > 0 {
+		n := len(tokens)
+		if n > datastoreBatchSize {
+			n = datastoreBatchSize
+		}
+		batch := tokens[:n]
+		tokens = tokens[n:]
+
+		keys, stats, err := s.getMulti(ctx, batch)
+		if err != nil {
+			return err
+		}
+		for i, t := range batch {
+			stats[i].Count += deltas[t]
+			if stats[i].Count < 0 {
+				stats[i].Count = 0
+			}
+		}
+		if _, err := datastore.PutMulti(ctx, keys, stats); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Counts はトークンごとの件数を返す
+func (s *DatastoreTokenStats) Counts(ctx context.Context, tokens []string) (map[string]int, error) {
+	counts := make(map[string]int, len(tokens))
+	for len(tokens) > 0 {
+		n := len(tokens)
+		if n > datastoreBatchSize {
+			n = datastoreBatchSize
+		}
+		batch := tokens[:n]
+		tokens = tokens[n:]
+
+		_, stats, err := s.getMulti(ctx, batch)
+		if err != nil {
+			return nil, err
+		}
+		for i, t := range batch {
+			counts[t] = stats[i].Count
+		}
+	}
+	return counts, nil
+}
+
+// getMulti はトークンの件数を取得する。記録のないトークンは0件とする
+func (s *DatastoreTokenStats) getMulti(ctx context.Context, tokens []string) ([]*datastore.Key, []*tokenStat, error) {
+	var (
+		keys  = make([]*datastore.Key, len(tokens))
+		stats = make([]*tokenStat, len(tokens))
+	)
+	for i, t := range tokens {
+		keys[i] = datastore.NewKey(ctx, s.Kind, t, 0, nil)
+		stats[i] = new(tokenStat)
+	}
+
+	err := datastore.GetMulti(ctx, keys, stats)
+	if merr, ok := err.(appengine.MultiError); ok {
+		for i, e := range merr {
+			if e == datastore.ErrNoSuchEntity {
+				stats[i] = new(tokenStat)
+			} else if e != nil {
+				return nil, nil, err
+			}
+		}
+	} else if err != nil {
+		return nil, nil, err
+	}
+	return keys, stats, nil
+}
+
+// MemoryTokenStats はメモリ上にトークンごとの件数を保持する TokenStats
+type MemoryTokenStats struct {
+	mu     sync.RWMutex
+	counts map[string]int
+}
+
+// NewMemoryTokenStats は空の MemoryTokenStats を返す
+func NewMemoryTokenStats() *MemoryTokenStats {
+	return &MemoryTokenStats{counts: make(map[string]int)}
+}
+
+// Add はトークンごとの件数に差分を加える
+func (s *MemoryTokenStats) Add(ctx context.Context, deltas map[string]int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for t, d := range deltas {
+		s.counts[t] += d
+		if s.counts[t] <= 0 {
+			delete(s.counts, t)
+		}
+	}
+	return nil
+}
+
+// Counts はトークンごとの件数を返す
+func (s *MemoryTokenStats) Counts(ctx context.Context, tokens []string) (map[string]int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	counts := make(map[string]int, len(tokens))
+	for _, t := range tokens {
+		counts[t] = s.counts[t]
+	}
+	return counts, nil
+}
@@ -0,0 +1,48 @@
+package foosearch
+
+import (
+	"golang.org/x/net/context"
+	"google.golang.org/appengine/datastore"
+)
+
+type afterCommitKey struct{}
+
+// afterCommitHooks はトランザクションのコミット後に実行する処理
+type afterCommitHooks struct {
+	funcs []func(ctx context.Context)
+}
+
+// runInTransaction はトランザクション内で f を実行し、コミットに成功した場合は afterCommit で登録された処理を実行する
+//
+// トークンの件数のように、Fooとは別のエンティティグループを多数更新する処理はトランザクションに含められないため、
+// コミット後にトランザクションの外で実行する。
+func runInTransaction(ctx context.Context, f func(tc context.Context) error) error {
+	hooks := new(afterCommitHooks)
+	err := datastore.RunInTransaction(ctx, func(tc context.Context) error {
+		// 競合によって再実行された場合は、前回の実行で登録された処理を破棄する
+		hooks.funcs = nil
+		return f(context.WithValue(tc, afterCommitKey{}, hooks))
+	}, nil)
+	if err != nil {
+		return err
+	}
+	for _, fn := range hooks.funcs {
+		fn(ctx)
+	}
+	return nil
+}
+
+// afterCommit は runInTransaction のトランザクション内であればコミット後に、それ以外の場合はすぐに f を実行する
+func afterCommit(ctx context.Context, f func(ctx context.Context)) {
+	if hooks, ok := ctx.Value(afterCommitKey{}).(*afterCommitHooks); ok {
+		hooks.funcs = append(hooks.funcs, f)
+		return
+	}
+	f(ctx)
+}
+
+// inTransaction は ctx が runInTransaction のトランザクション内のものかどうかを返す
+func inTransaction(ctx context.Context) bool {
+	_, ok := ctx.Value(afterCommitKey{}).(*afterCommitHooks)
+	return ok
+}
@@ -0,0 +1,21 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/ryutah/gaego-search-sample/foosearch"
+)
+
+func init() {
+	// 正規化した値で検索するため等価フィルタと比較フィルタは使用せず、Search プロパティ以外のインデックスは作成しない
+	repo := foosearch.NewDatastoreRepository("fooQuery")
+	repo.NoIndex = true
+	s := foosearch.NewQuerySearcher(repo)
+	// 全角・半角、大文字・小文字、カタカナ・ひらがなの違いを区別せずに検索できるよう正規化する
+	// 項目を指定した検索語も正規化し、読みを含めて比較する
+	s.Normalizer = &foosearch.Normalizer{Fold: true, Kana: true}
+	// 件数の少ないトークンを優先してクエリの条件とするため、トークンごとの件数を保存する
+	s.Stats = foosearch.NewDatastoreTokenStats("fooQueryToken")
+
+	http.Handle("/", foosearch.NewRouter(s))
+}